@@ -1,3 +1,3 @@
 #!/bin/sh
-go build -ldflags="-s -w" -o  LazyPLCNext.exe main.go
+go build -ldflags="-s -w" -o LazyPLCNext.exe .
 echo Done.
\ No newline at end of file
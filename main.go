@@ -2,29 +2,51 @@ package main
 
 import (
 	"archive/zip"
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/user"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime/debug"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
 	"time"
 
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/minio/selfupdate"
 	"github.com/shirou/gopsutil/v3/process"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
 )
 
 // ======================================================================================
@@ -32,12 +54,18 @@ import (
 // ======================================================================================
 
 const (
-	ConfigFileName      = "launcher_config.json"
-	LogFileName         = "plcnext_launcher.log"
-	IDEBasePath         = `C:\Program Files\PHOENIX CONTACT`
-	RepoOwner           = "suprunchuk"
-	RepoName            = "LazyPLCNext"
-	UpdateCheckInterval = time.Minute * 1
+	ConfigFileName         = "launcher_config.json"
+	LogFileName            = "plcnext_launcher.log"
+	MetricsFileName        = "launch_metrics.jsonl"
+	ResumeFileName         = "resume_state.json" // UI state carried over an update restart
+	MaxMetricsFileSize     = 1 << 20             // Rotate the metrics file after 1 MiB
+	IDEBasePath            = `C:\Program Files\PHOENIX CONTACT`
+	RepoOwner              = "suprunchuk"
+	RepoName               = "LazyPLCNext"
+	UpdateCheckInterval    = time.Minute * 60 // Default, overridden by Config.UpdateCheckIntervalMinutes
+	MinUpdateCheckInterval = time.Minute * 5  // Lower bound to avoid spamming the GitHub API
+	SlowLaunchHint         = time.Second * 15 // Default, overridden by Config.SlowLaunchHintSec
+	MaxPath                = 260              // Windows MAX_PATH, longer paths need special handling
 )
 
 var AppVersion = "dev"
@@ -94,6 +122,14 @@ var (
 			Foreground(colText).
 			Background(colSecondary)
 
+	tagBadgeStyle = badgeStyle.Copy().
+			Foreground(colText).
+			Background(colPath)
+
+	warnBadgeStyle = badgeStyle.Copy().
+			Foreground(colText).
+			Background(colError)
+
 	// Selected Item
 	selectedItemStyle = lipgloss.NewStyle().
 				Border(lipgloss.ThickBorder(), false, false, false, true).
@@ -102,6 +138,23 @@ var (
 				Padding(0, 0, 0, 1).
 				Bold(true)
 
+	// Description under the selected title, aligned with it but without the bar.
+	selectedDescStyle = selectedItemStyle.Copy().UnsetBorderStyle()
+
+	groupTitleStyle   = lipgloss.NewStyle().Foreground(colAccent).Bold(true)
+	problemTitleStyle = lipgloss.NewStyle().Foreground(colError).Bold(true)
+
+	// Git sync markers next to the branch badge
+	markerWarnStyle  = lipgloss.NewStyle().Foreground(colError)
+	markerDirtyStyle = lipgloss.NewStyle().Foreground(colGit)
+	markerSyncStyle  = lipgloss.NewStyle().Foreground(colAccent)
+	markerCleanStyle = lipgloss.NewStyle().Foreground(colPrimary)
+
+	warnTextStyle = lipgloss.NewStyle().Foreground(colError).Bold(true)
+
+	// Values that differ between the two projects in the compare view
+	compareDiffStyle = lipgloss.NewStyle().Foreground(colAccent).Bold(true)
+
 	// Box/Panel Styles
 	boxStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
@@ -117,8 +170,134 @@ var (
 // ======================================================================================
 
 type Config struct {
-	WorkDirs     []string `json:"work_dirs"`
-	UseNerdFonts bool     `json:"use_nerd_fonts"`
+	WorkDirs                   []string             `json:"work_dirs"`
+	UseNerdFonts               bool                 `json:"use_nerd_fonts"`
+	DefaultIDEVersion          string               `json:"default_ide_version"`           // Used when the project version is not installed
+	AutoUpdate                 bool                 `json:"auto_update"`                   // Apply updates without asking and restart
+	DisableUpdateCheck         bool                 `json:"disable_update_check"`          // Never contact GitHub (closed networks)
+	UpdateCheckIntervalMinutes int                  `json:"update_check_interval_minutes"` // 0 = default
+	FollowSymlinks             bool                 `json:"follow_symlinks"`               // Descend into symlinks/junctions while scanning
+	SlowLaunchHintSec          int                  `json:"slow_launch_hint_sec"`          // Seconds before the "IDE is slow to start" hint, 0 = default
+	IDELanguage                string               `json:"ide_language"`                  // e.g. "en-US", passed via IDELanguageArg
+	IDELanguageArg             string               `json:"ide_language_arg"`              // Argument template, "{lang}" is replaced by IDELanguage
+	Notifications              bool                 `json:"notifications"`                 // Toast + bell when the IDE started or an update was applied
+	LaunchTimes                map[string]time.Time `json:"launch_times,omitempty"`        // Project path -> last successful launch
+	LaunchCounts               map[string]int       `json:"launch_counts,omitempty"`       // Project path -> number of successful launches
+	SortMode                   string               `json:"sort_mode"`                     // "name" (default) or "launches"
+	LockFilePatterns           []string             `json:"lock_file_patterns,omitempty"`  // Glob patterns of IDE lock files ({name}/{base} = project name), empty = DefaultLockFilePatterns
+	Inline                     bool                 `json:"inline"`                        // Run without the alternate screen, output stays in terminal history
+	ConfirmLaunch              bool                 `json:"confirm_launch"`                // Require a second Enter to launch
+	EditorCommand              string               `json:"editor_command"`                // Editor for project folders (default: code)
+	PreLaunchCommand           string               `json:"pre_launch_command"`            // Run in the project folder before launch, failure aborts; {path} {version} {branch}
+	PostLaunchCommand          string               `json:"post_launch_command"`           // Run in the project folder after launch, failure is only logged
+	WebhookURL                 string               `json:"webhook_url"`                   // POST a JSON launch notice here (chat/audit), optional
+	GroupBy                    string               `json:"group_by"`                      // "" (flat list) or "version"
+	Sessions                   map[string][]string  `json:"sessions"`                      // Named sets of project paths opened together
+	FocusAttempts              int                  `json:"focus_attempts"`                // Tries to focus a running IDE window (default 5)
+	FocusDelayMs               int                  `json:"focus_delay_ms"`                // Pause before each focus attempt (default 300)
+	LaunchMode                 string               `json:"launch_mode"`                   // "direct" (default, pick the IDE exe) or "association" (let Windows open .pcwef/.pcwex)
+	ScanTimeoutSec             int                  `json:"scan_timeout_sec"`              // Limit for scanning all work dirs (default 120)
+	ProblemSection             bool                 `json:"problem_section"`               // List projects without version/IDE or with a broken archive in a section at the end
+	HistoryLimit               *int                 `json:"history_limit,omitempty"`       // Projects kept in launch history/statistics (default 200), 0 = keep none
+	ProjectIDEVersion          map[string]string    `json:"project_ide_version,omitempty"` // Project path -> IDE version chosen for it, wins over the project version
+	WarnNetworkLaunch          bool                 `json:"warn_network_launch"`           // Ask before opening a project from a network, removable or cloud drive
+	IconRules                  map[string]string    `json:"icon_rules,omitempty"`          // Name/path pattern (* and ?) -> icon, e.g. "*\\Customers\\Acme\\*": "🏭"
+	AutoOpenSingleMatch        bool                 `json:"auto_open_single_match"`        // Open the details of the only project left after accepting a filter
+	Tags                       map[string][]string  `json:"tags,omitempty"`                // Project path -> labels, shown as badges and filterable with #tag
+	Archived                   []string             `json:"archived,omitempty"`            // Project paths hidden from the list unless archived projects are shown
+	CleanDirs                  []string             `json:"clean_dirs,omitempty"`          // Folder names removed by clean in flat projects, empty = DefaultCleanDirs
+	DefaultAction              string               `json:"default_action,omitempty"`      // Enter in the list: "launch" (default), "details" or "folder"
+}
+
+func (c Config) slowLaunchHint() time.Duration {
+	if c.SlowLaunchHintSec <= 0 {
+		return SlowLaunchHint
+	}
+	return time.Duration(c.SlowLaunchHintSec) * time.Second
+}
+
+// Defaults for focusing an already running IDE window.
+const (
+	DefaultFocusAttempts = 5
+	DefaultFocusDelay    = 300 * time.Millisecond
+)
+
+func (c Config) focusAttempts() int {
+	if c.FocusAttempts <= 0 {
+		return DefaultFocusAttempts
+	}
+	return c.FocusAttempts
+}
+
+func (c Config) focusDelay() time.Duration {
+	if c.FocusDelayMs <= 0 {
+		return DefaultFocusDelay
+	}
+	return time.Duration(c.FocusDelayMs) * time.Millisecond
+}
+
+// ScanStaleAfter highlights the scan time in the status line, the list may
+// no longer match the disk.
+const ScanStaleAfter = time.Hour
+
+// DefaultScanTimeout bounds a whole scan, a hung network drive must not
+// freeze the tool.
+const DefaultScanTimeout = 2 * time.Minute
+
+func (c Config) scanTimeout() time.Duration {
+	if c.ScanTimeoutSec <= 0 {
+		return DefaultScanTimeout
+	}
+	return time.Duration(c.ScanTimeoutSec) * time.Second
+}
+
+// DefaultHistoryLimit is the number of projects kept in the launch history.
+const DefaultHistoryLimit = 200
+
+// historyLimit is Config.HistoryLimit; unset means the default, 0 disables history.
+func (c Config) historyLimit() int {
+	if c.HistoryLimit == nil || *c.HistoryLimit < 0 {
+		return DefaultHistoryLimit
+	}
+	return *c.HistoryLimit
+}
+
+// Actions for Enter in the project list (Config.DefaultAction).
+const (
+	DefaultActionLaunch  = "launch"
+	DefaultActionDetails = "details"
+	DefaultActionFolder  = "folder"
+)
+
+// defaultAction returns Config.DefaultAction, unknown values launch.
+func (c Config) defaultAction() string {
+	switch c.DefaultAction {
+	case DefaultActionDetails, DefaultActionFolder:
+		return c.DefaultAction
+	}
+	return DefaultActionLaunch
+}
+
+// DefaultEditorCommand opens project folders in VS Code.
+const DefaultEditorCommand = "code"
+
+func (c Config) editorCommand() string {
+	if c.EditorCommand == "" {
+		return DefaultEditorCommand
+	}
+	return c.EditorCommand
+}
+
+// updateCheckInterval returns the configured interval clamped to MinUpdateCheckInterval.
+func (c Config) updateCheckInterval() time.Duration {
+	if c.UpdateCheckIntervalMinutes <= 0 {
+		return UpdateCheckInterval
+	}
+	interval := time.Duration(c.UpdateCheckIntervalMinutes) * time.Minute
+	if interval < MinUpdateCheckInterval {
+		return MinUpdateCheckInterval
+	}
+	return interval
 }
 
 type ProjectType int
@@ -137,10 +316,122 @@ type ProjectInfo struct {
 	Version   string
 	IsPCWEF   bool
 	GitBranch string // New field for Git Branch
+	Git       GitStatus
+
+	VersionPending bool // archive version is still being read in the background
+	GitPending     bool // git info is still being read in the background
+
+	// MixedVersions lists "file: version" for every metadata file of a flat
+	// project when they disagree (typically a migration in progress).
+	MixedVersions []string
+
+	Broken bool // the archive cannot be opened
+
+	Tags     []string // Config.Tags of the project, attached when the list is built
+	Archived bool     // listed in Config.Archived
+
+	// FlatVersion is the version of the unpacked flat folder next to a .pcwex
+	// when it differs from the archive (the archive may be outdated).
+	FlatVersion string
+}
+
+// iconRule is a compiled Config.IconRules entry.
+type iconRule struct {
+	re     *regexp.Regexp
+	icon   string
+	onPath bool // the pattern has a separator and is matched against the path
+}
+
+// compileIconRules turns the configured patterns into rules, most specific
+// (longest) pattern first. Empty icons and unusable patterns are skipped.
+func compileIconRules(rules map[string]string) []iconRule {
+	patterns := make([]string, 0, len(rules))
+	for pattern := range rules {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	var compiled []iconRule
+	for _, pattern := range patterns {
+		icon := strings.TrimSpace(rules[pattern])
+		if pattern == "" || icon == "" {
+			WriteLog(fmt.Sprintf("Ignoring icon rule %q: empty pattern or icon", pattern))
+			continue
+		}
+		norm := strings.ToLower(strings.ReplaceAll(pattern, `\`, "/"))
+		expr := regexp.QuoteMeta(norm)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			WriteLog(fmt.Sprintf("Ignoring icon rule %q: %v", pattern, err))
+			continue
+		}
+		compiled = append(compiled, iconRule{re: re, icon: icon, onPath: strings.Contains(norm, "/")})
+	}
+	return compiled
+}
+
+// ruleIcon returns the icon of the first rule matching p, or "".
+func ruleIcon(rules []iconRule, p ProjectInfo) string {
+	for _, r := range rules {
+		target := p.Name
+		if r.onPath {
+			target = strings.ReplaceAll(p.Path, `\`, "/")
+		}
+		if r.re.MatchString(strings.ToLower(target)) {
+			return r.icon
+		}
+	}
+	return ""
+}
+
+// Icon returns the list icon for the project type: emoji by default, Nerd
+// Font glyphs (folder, link, archive) when nerdFonts is set.
+func (t ProjectType) Icon(nerdFonts bool) string {
+	if nerdFonts {
+		switch t {
+		case TypeFlat:
+			return "\uf07b"
+		case TypePCWEF:
+			return "\uf0c1"
+		}
+		return "\uf1c6"
+	}
+	switch t {
+	case TypeFlat:
+		return "📂"
+	case TypePCWEF:
+		return "🔗"
+	}
+	return "📦"
+}
+
+// Label returns the short badge text for the project type.
+func (t ProjectType) Label() string {
+	switch t {
+	case TypeFlat:
+		return "DIR"
+	case TypePCWEF:
+		return "PCWEF"
+	case TypePCWEX:
+		return "PCWEX"
+	}
+	return "UNKNOWN"
 }
 
 // Implement list.Item interface
-func (p ProjectInfo) FilterValue() string { return p.Name }
+// FilterValue is the name followed by the tags as "#tag", see tagFilter.
+func (p ProjectInfo) FilterValue() string {
+	if len(p.Tags) == 0 {
+		return p.Name
+	}
+	return p.Name + " #" + strings.Join(p.Tags, " #")
+}
 func (p ProjectInfo) Title() string       { return p.Name }
 func (p ProjectInfo) Description() string { return p.Path }
 
@@ -156,10 +447,42 @@ type GitHubRelease struct {
 	} `json:"assets"`
 }
 
+// updateChecksPausedUntil (Unix nanoseconds) is set when GitHub reports an
+// exhausted rate limit. Checks are skipped until the reported reset time.
+// Atomic since the checks run in tea.Cmd goroutines.
+var updateChecksPausedUntil atomic.Int64
+
+func updateChecksPaused() bool {
+	return time.Now().UnixNano() < updateChecksPausedUntil.Load()
+}
+
+// handleRateLimit logs the remaining GitHub API quota and pauses further
+// checks until X-RateLimit-Reset once it is exhausted.
+func handleRateLimit(resp *http.Response) {
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		return
+	}
+	WriteLog("GitHub API rate limit remaining: " + remaining)
+	if remaining != "0" {
+		return
+	}
+	var reset int64
+	until := time.Now().Add(time.Hour)
+	if _, err := fmt.Sscanf(resp.Header.Get("X-RateLimit-Reset"), "%d", &reset); err == nil && reset > 0 {
+		until = time.Unix(reset, 0)
+	}
+	updateChecksPausedUntil.Store(until.UnixNano())
+	WriteLog(fmt.Sprintf("GitHub API rate limit exhausted, pausing update checks until %s", until.Format("15:04:05")))
+}
+
 func checkUpdate() (string, string, error) {
 	if AppVersion == "dev" {
 		return "", "", nil
 	}
+	if updateChecksPaused() {
+		return "", "", nil
+	}
 	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", RepoOwner, RepoName)
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Get(url)
@@ -167,6 +490,12 @@ func checkUpdate() (string, string, error) {
 		return "", "", err
 	}
 	defer resp.Body.Close()
+	handleRateLimit(resp)
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		if updateChecksPaused() {
+			return "", "", fmt.Errorf("github api rate limit exceeded")
+		}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", "", fmt.Errorf("github api status: %s", resp.Status)
 	}
@@ -184,19 +513,75 @@ func checkUpdate() (string, string, error) {
 	return "", "", nil
 }
 
-func doUpdate(url string) error {
+// progressReader reports the bytes read so far to report, at most every 100ms.
+type progressReader struct {
+	r      io.Reader
+	read   int64
+	total  int64
+	last   time.Time
+	report func(read, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if time.Since(p.last) >= 100*time.Millisecond || err == io.EOF {
+		p.last = time.Now()
+		p.report(p.read, p.total)
+	}
+	return n, err
+}
+
+// doUpdate downloads the new binary and replaces the running one. progress
+// receives the downloaded and total bytes (total is 0 without Content-Length).
+func doUpdate(url string, progress func(read, total int64)) error {
 	resp, err := http.Get(url)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	err = selfupdate.Apply(resp.Body, selfupdate.Options{})
+	if resp.ContentLength > 0 {
+		// selfupdate writes the new binary next to the running one.
+		if exe, err := os.Executable(); err == nil {
+			if err := ensureFreeSpace(filepath.Dir(exe), uint64(resp.ContentLength)); err != nil {
+				return err
+			}
+		}
+	}
+	body := &progressReader{r: resp.Body, total: max(resp.ContentLength, 0), report: progress}
+	err = selfupdate.Apply(body, selfupdate.Options{})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// StaleTempAge is how old a leftover temp file must be before another run
+// removes it (younger ones may belong to a running instance).
+const StaleTempAge = 24 * time.Hour
+
+// cleanupTempExtracts removes leftovers of interrupted config writes. Archive
+// previews are read in memory and backups go to BackupDirName, so nothing
+// else is extracted to temporary locations.
+func cleanupTempExtracts() {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	stale, _ := filepath.Glob(filepath.Join(filepath.Dir(exe), ConfigFileName+".*.tmp"))
+	for _, p := range stale {
+		info, err := os.Stat(p)
+		if err != nil || time.Since(info.ModTime()) <= StaleTempAge {
+			continue
+		}
+		if err := os.RemoveAll(p); err != nil {
+			WriteLog(fmt.Sprintf("Failed to remove temp %s: %v", p, err))
+			continue
+		}
+		WriteLog("Removed temp: " + p)
+	}
+}
+
 func cleanupOldVersion() {
 	exe, err := os.Executable()
 	if err != nil {
@@ -208,6 +593,7 @@ func cleanupOldVersion() {
 	}
 }
 
+// restartApp starts the exe again with the same arguments and exits.
 func restartApp() {
 	exe, err := os.Executable()
 	if err != nil {
@@ -218,6 +604,8 @@ func restartApp() {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	// The new process must not find this one still holding the instance mutex.
+	releaseInstance()
 	if err := cmd.Start(); err != nil {
 		WriteLog(fmt.Sprintf("Failed to restart: %v", err))
 		return
@@ -225,14 +613,135 @@ func restartApp() {
 	os.Exit(0)
 }
 
+// ResumeState is the UI state saved before an update restart and restored
+// by the new version.
+type ResumeState struct {
+	Selected string   `json:"selected,omitempty"`
+	Filter   string   `json:"filter,omitempty"`
+	Marked   []string `json:"marked,omitempty"`
+}
+
+func resumeFilePath() string {
+	exe, _ := os.Executable()
+	return filepath.Join(filepath.Dir(exe), ResumeFileName)
+}
+
+// restart saves the config and the list state and marks the model for a
+// restart: main starts the updated exe once the program has quit and the
+// terminal is restored. The caller returns tea.Quit.
+func (m *model) restart() {
+	if err := saveConfig(m.config); err != nil {
+		WriteLog(fmt.Sprintf("Failed to save config before restart: %v", err))
+	}
+	if m.listReady {
+		st := ResumeState{Selected: m.lastListPath}
+		if p, ok := m.list.SelectedItem().(ProjectInfo); ok && m.state == StateList {
+			st.Selected = p.Path
+		}
+		if m.list.FilterState() == list.FilterApplied {
+			st.Filter = m.list.FilterValue()
+		}
+		for path := range m.marked {
+			st.Marked = append(st.Marked, path)
+		}
+		if data, err := json.Marshal(st); err == nil {
+			if err := os.WriteFile(resumeFilePath(), data, 0644); err != nil {
+				WriteLog(fmt.Sprintf("Failed to save UI state before restart: %v", err))
+			}
+		}
+	}
+	m.restartOnExit = true
+}
+
+// restoreResumeState applies and removes the state saved by restart.
+func (m *model) restoreResumeState() {
+	data, err := os.ReadFile(resumeFilePath())
+	if err != nil {
+		return
+	}
+	os.Remove(resumeFilePath())
+	var st ResumeState
+	if err := json.Unmarshal(data, &st); err != nil {
+		WriteLog(fmt.Sprintf("Ignoring UI state from before the update: %v", err))
+		return
+	}
+	for _, path := range st.Marked {
+		m.marked[path] = true
+	}
+	if st.Filter != "" {
+		m.list.SetFilterText(st.Filter)
+	}
+	if st.Selected != "" {
+		m.lastListPath = st.Selected
+		m.restoreListPosition()
+	}
+	WriteLog("Restored UI state from before the update")
+}
+
 // ======================================================================================
 // BUSINESS LOGIC
 // ======================================================================================
 
+// MetricEvent is one line of MetricsFileName. Fields are only ever added, so
+// scripts reading the file keep working across versions.
+type MetricEvent struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	Project    string    `json:"project"`
+	Path       string    `json:"path"`
+	Version    string    `json:"version"`
+	Branch     string    `json:"branch,omitempty"`
+	ReadOnly   bool      `json:"read_only,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+var metricsMu sync.Mutex
+
+// writeMetric appends e to the JSONL metrics file next to the config. When the
+// file grows past MaxMetricsFileSize it is rotated to <name>.1.
+func writeMetric(e MetricEvent) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		WriteLog(fmt.Sprintf("Metrics error: %v", err))
+		return
+	}
+	exePath, _ := os.Executable()
+	path := filepath.Join(filepath.Dir(exePath), MetricsFileName)
+
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if info, err := os.Stat(path); err == nil && info.Size() >= MaxMetricsFileSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			WriteLog(fmt.Sprintf("Metrics rotation failed: %v", err))
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		WriteLog(fmt.Sprintf("Metrics error: %v", err))
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// logPanic is deferred by Update and View: it writes the panic with its stack
+// to the log and re-panics so Bubble Tea restores the terminal and exits.
+func logPanic(where string) {
+	if r := recover(); r != nil {
+		WriteLog(fmt.Sprintf("PANIC in %s: %v\n%s", where, r, debug.Stack()))
+		panic(r)
+	}
+}
+
+// logFilePath is the human-readable log in %TEMP%.
+func logFilePath() string {
+	return filepath.Join(os.Getenv("TEMP"), LogFileName)
+}
+
 func WriteLog(msg string) {
-	temp := os.Getenv("TEMP")
-	logPath := filepath.Join(temp, LogFileName)
-	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	f, err := os.OpenFile(logFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return
 	}
@@ -241,8 +750,42 @@ func WriteLog(msg string) {
 	f.WriteString(fmt.Sprintf("[%s] %s\n", timestamp, msg))
 }
 
+// normalizeXML strips a UTF-8 byte order mark and converts UTF-16 files that
+// start with one to UTF-8. Other encodings are left to xmlCharsetReader.
+func normalizeXML(content []byte) []byte {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		return content[3:]
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}), bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		dec := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()
+		if out, err := dec.Bytes(content); err == nil {
+			return out
+		}
+	}
+	return content
+}
+
+// xmlCharsetReader decodes the encoding named in the XML declaration
+// (windows-1251, iso-8859-1 and the like from localized installations).
+func xmlCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "utf-16", "utf-16le", "utf-16be":
+		// Already converted by normalizeXML, only the declaration still says UTF-16.
+		return input, nil
+	}
+	enc, err := ianaindex.IANA.Encoding(label)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("unsupported XML encoding %q", label)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
 func findVersionInXML(r io.Reader) string {
 	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = xmlCharsetReader
 	for {
 		t, _ := decoder.Token()
 		if t == nil {
@@ -269,14 +812,117 @@ func findVersionInXML(r io.Reader) string {
 	return ""
 }
 
+// findLibrariesInXML lists the libraries and firmware referenced by project
+// metadata: elements whose name contains "Library" (Library, LibraryReference,
+// ...) with a Name attribute, and Property elements with a *Firmware* key.
+// Entries are "name version", duplicates are dropped.
+func findLibrariesInXML(r io.Reader) []string {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = xmlCharsetReader
+	var libs []string
+	seen := make(map[string]bool)
+	add := func(entry string) {
+		entry = strings.TrimSpace(entry)
+		if entry != "" && !seen[entry] {
+			seen[entry] = true
+			libs = append(libs, entry)
+		}
+	}
+	for {
+		t, err := decoder.Token()
+		if t == nil || err != nil {
+			break
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		attrs := make(map[string]string, len(se.Attr))
+		for _, a := range se.Attr {
+			attrs[strings.ToLower(a.Name.Local)] = a.Value
+		}
+		switch {
+		case strings.Contains(strings.ToLower(se.Name.Local), "library") && attrs["name"] != "":
+			add(attrs["name"] + " " + attrs["version"])
+		case se.Name.Local == "Property" && strings.Contains(strings.ToLower(attrs["key"]), "firmware") && attrs["value"] != "":
+			add(attrs["key"] + " " + attrs["value"])
+		}
+	}
+	return libs
+}
+
+// projectLibraries reads the libraries of p from Solution.xml and
+// additional.xml (inside the archive for .pcwex, in the flat folder otherwise).
+func projectLibraries(p ProjectInfo) ([]string, error) {
+	var contents [][]byte
+	switch p.Type {
+	case TypePCWEX:
+		r, err := zip.OpenReader(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			base := strings.ToLower(path.Base(strings.ReplaceAll(f.Name, `\`, "/")))
+			if base != "solution.xml" && base != "additional.xml" {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				continue
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err == nil {
+				contents = append(contents, data)
+			}
+		}
+	default:
+		folder, ok := flatFolder(p)
+		if !ok {
+			return nil, nil
+		}
+		files := []string{filepath.Join(folder, "_properties", "additional.xml")}
+		for _, name := range SolutionFileNames {
+			files = append(files, filepath.Join(folder, name))
+		}
+		for _, file := range files {
+			if data, err := os.ReadFile(file); err == nil {
+				contents = append(contents, data)
+			}
+		}
+	}
+	var libs []string
+	seen := make(map[string]bool)
+	for _, data := range contents {
+		for _, lib := range findLibrariesInXML(bytes.NewReader(normalizeXML(data))) {
+			if !seen[lib] {
+				seen[lib] = true
+				libs = append(libs, lib)
+			}
+		}
+	}
+	return libs, nil
+}
+
+// MaxCleanListed caps the folders listed in the clean confirmation.
+const MaxCleanListed = 8
+
+// MaxDetailsLibraries caps the library rows in the details panel.
+const MaxDetailsLibraries = 10
+
+// ProductVersion attribute in either order, for XML the decoder rejects.
+var (
+	productVersionRe    = regexp.MustCompile(`Key="ProductVersion"[^>]*Value="([^"]+)"`)
+	productVersionRevRe = regexp.MustCompile(`Value="([^"]+)"[^>]*Key="ProductVersion"`)
+)
+
 func findVersionRegex(content []byte) string {
-	re := regexp.MustCompile(`Key="ProductVersion"[^>]*Value="([^"]+)"`)
-	matches := re.FindStringSubmatch(string(content))
+	matches := productVersionRe.FindStringSubmatch(string(content))
 	if len(matches) > 1 {
 		return matches[1]
 	}
-	re2 := regexp.MustCompile(`Value="([^"]+)"[^>]*Key="ProductVersion"`)
-	matches2 := re2.FindStringSubmatch(string(content))
+	matches2 := productVersionRevRe.FindStringSubmatch(string(content))
 	if len(matches2) > 1 {
 		return matches2[1]
 	}
@@ -290,29 +936,100 @@ func extractVersionFromZip(path string) (string, error) {
 	}
 	defer r.Close()
 
+	var candidates []*zip.File
 	for _, f := range r.File {
-		if strings.HasSuffix(strings.ToLower(f.Name), "additional.xml") {
-			rc, err := f.Open()
-			if err != nil {
-				continue
-			}
-			content, err := io.ReadAll(rc)
-			rc.Close()
-			if err != nil {
-				continue
-			}
-			if ver := findVersionInXML(strings.NewReader(string(content))); ver != "" {
-				return ver, nil
-			}
-			if ver := findVersionRegex(content); ver != "" {
-				return ver, nil
-			}
+		if additionalXMLRank(f.Name) >= 0 {
+			candidates = append(candidates, f)
 		}
 	}
-	return "", fmt.Errorf("version not found")
+	// Archive order is arbitrary, read the most authoritative file first.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return additionalXMLRank(candidates[i].Name) < additionalXMLRank(candidates[j].Name)
+	})
+	for _, f := range candidates {
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			continue
+		}
+		content = normalizeXML(content)
+		if ver := findVersionInXML(bytes.NewReader(content)); ver != "" {
+			return ver, nil
+		}
+		if ver := findVersionRegex(content); ver != "" {
+			return ver, nil
+		}
+	}
+	return "", errVersionNotFound
+}
+
+// additionalXMLRank orders additional.xml entries of an archive: 0 inside a
+// _properties folder, 1 at the root, 2 anywhere else, -1 for other files.
+func additionalXMLRank(name string) int {
+	name = strings.ToLower(strings.ReplaceAll(name, `\`, "/"))
+	if !strings.HasSuffix(name, "additional.xml") {
+		return -1
+	}
+	dir := path.Dir(name)
+	switch {
+	case path.Base(dir) == "_properties":
+		return 0
+	case dir == ".":
+		return 1
+	}
+	return 2
 }
 
-func extractVersionFromFolder(folderPath string) string {
+// errVersionNotFound is returned for a readable archive without version
+// metadata.
+var errVersionNotFound = errors.New("version not found")
+
+// archiveVersion returns the version stored in a .pcwex or "Unknown".
+func archiveVersion(path string) string {
+	ver, _ := archiveVersionStatus(path)
+	return ver
+}
+
+// flatVersionMismatch returns the version of the flat folder unpacked next to
+// the archive at path ("<name>Flat" or "<name>") when it differs from ver.
+func flatVersionMismatch(path, ver string) string {
+	if ver == "" || ver == "Unknown" {
+		return ""
+	}
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for _, dir := range []string{base + "Flat", base} {
+		folder := filepath.Join(filepath.Dir(path), dir)
+		if !hasSolutionFile(folder) {
+			continue
+		}
+		flat, _ := extractVersionFromFolder(folder)
+		if flat == "Unknown" || flat == ver {
+			return ""
+		}
+		WriteLog(fmt.Sprintf("Version mismatch: %s is %s, flat folder %s is %s", path, ver, folder, flat))
+		return flat
+	}
+	return ""
+}
+
+// archiveVersionStatus is archiveVersion that also reports an archive which
+// cannot be read at all (as opposed to one without version metadata).
+func archiveVersionStatus(path string) (ver string, broken bool) {
+	ver, err := extractVersionFromZip(path)
+	if ver == "" {
+		return "Unknown", err != nil && !errors.Is(err, errVersionNotFound)
+	}
+	return ver, false
+}
+
+// extractVersionFromFolder returns the version of a flat project folder.
+// additional.xml wins; when it and the StorageProperties*.xml files report
+// different versions, mixed lists each file with its version.
+func extractVersionFromFolder(folderPath string) (ver string, mixed []string) {
 	candidates := []string{
 		filepath.Join(folderPath, "_properties", "additional.xml"),
 	}
@@ -324,42 +1041,111 @@ func extractVersionFromFolder(folderPath string) string {
 			}
 		}
 	}
+	var found []string
+	distinct := make(map[string]bool)
 	for _, file := range candidates {
 		content, err := os.ReadFile(file)
 		if err != nil {
 			continue
 		}
-		if ver := findVersionInXML(strings.NewReader(string(content))); ver != "" {
-			return ver
+		content = normalizeXML(content)
+		v := findVersionInXML(bytes.NewReader(content))
+		if v == "" {
+			v = findVersionRegex(content)
 		}
-		if ver := findVersionRegex(content); ver != "" {
-			return ver
+		if v == "" {
+			continue
+		}
+		if ver == "" {
+			ver = v
 		}
+		distinct[v] = true
+		found = append(found, filepath.Base(file)+": "+v)
+	}
+	if ver == "" {
+		return "Unknown", nil
 	}
-	return "Unknown"
+	if len(distinct) > 1 {
+		WriteLog(fmt.Sprintf("Mixed versions in %s: %s", folderPath, strings.Join(found, ", ")))
+		return ver, found
+	}
+	return ver, nil
 }
 
-func getGitBranch(startPath string) string {
-	dir := startPath
-	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
-		dir = filepath.Dir(dir)
+// GitCommandTimeout bounds every git invocation (slow network shares, hung credential helpers).
+const GitCommandTimeout = 5 * time.Second
+
+// SubmoduleInitTimeout bounds `git submodule update --init`, which may clone.
+const SubmoduleInitTimeout = 5 * time.Minute
+
+// gitVersion's cached result.
+var (
+	gitOnce       sync.Once
+	gitVersionStr string
+	gitVersionErr error
+)
+
+// gitVersion runs `git --version` once per session and caches the result.
+func gitVersion() (string, error) {
+	gitOnce.Do(func() {
+		out, err := exec.Command("git", "--version").Output()
+		if err != nil {
+			gitVersionErr = fmt.Errorf("git not available: %w", err)
+			WriteLog(fmt.Sprintf("%v, git features disabled", gitVersionErr))
+			return
+		}
+		gitVersionStr = strings.TrimPrefix(strings.TrimSpace(string(out)), "git version ")
+		WriteLog("Using git " + gitVersionStr)
+	})
+	return gitVersionStr, gitVersionErr
+}
+
+// gitAvailable reports whether git could be run, see gitVersion.
+func gitAvailable() bool {
+	_, err := gitVersion()
+	return err == nil
+}
+
+type gitCheckMsg struct{ err error }
+
+func gitCheckCmd() tea.Cmd {
+	return func() tea.Msg {
+		_, err := gitVersion()
+		return gitCheckMsg{err: err}
 	}
+}
+
+// runGit runs git in dir and returns trimmed stdout.
+func runGit(dir string, args ...string) (string, error) {
+	return runGitTimeout(dir, GitCommandTimeout, args...)
+}
 
-	runGit := func(d string) string {
-		cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-		cmd.Dir = d
-		var out bytes.Buffer
-		cmd.Stdout = &out
-		if err := cmd.Run(); err == nil {
-			return strings.TrimSpace(out.String())
+func runGitTimeout(dir string, timeout time.Duration, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
 		}
-		return ""
+		return "", err
 	}
+	return strings.TrimSpace(out.String()), nil
+}
 
+// findGitRoot walks up from startPath (max 3 levels) to the folder containing .git.
+func findGitRoot(startPath string) string {
+	dir := startPath
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
 	for i := 0; i < 3; i++ {
-		gitDir := filepath.Join(dir, ".git")
-		if _, err := os.Stat(gitDir); err == nil {
-			return runGit(dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
 		}
 		parent := filepath.Dir(dir)
 		if parent == dir {
@@ -370,22 +1156,460 @@ func getGitBranch(startPath string) string {
 	return ""
 }
 
-func ScanProjects(root string) []ProjectInfo {
+// GitStatus is the working tree state of the repository holding a project.
+type GitStatus struct {
+	Dirty    bool // uncommitted or untracked changes
+	Upstream bool // branch tracks a remote branch
+	Ahead    int
+	Behind   int
+	// Submodules counts submodules that are not initialized.
+	Submodules int
+}
+
+// parseGitStatus reads `git status --porcelain=v2 --branch` output.
+func parseGitStatus(out string) (string, GitStatus) {
+	var branch string
+	var st GitStatus
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			branch = strings.TrimPrefix(line, "# branch.head ")
+			if branch == "(detached)" {
+				branch = "HEAD"
+			}
+		case strings.HasPrefix(line, "# branch.upstream "):
+			st.Upstream = true
+		case strings.HasPrefix(line, "# branch.ab "):
+			fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &st.Ahead, &st.Behind)
+		case line != "" && !strings.HasPrefix(line, "#"):
+			st.Dirty = true
+		}
+	}
+	return branch, st
+}
+
+// getGitInfo returns the branch and status of the repository containing
+// startPath, or an empty branch if it is not under git.
+func getGitInfo(startPath string) (string, GitStatus) {
+	if !gitAvailable() {
+		return "", GitStatus{}
+	}
+	root := findGitRoot(startPath)
+	if root == "" {
+		return "", GitStatus{}
+	}
+	return gitInfoAt(root)
+}
+
+func gitInfoAt(root string) (string, GitStatus) {
+	out, err := runGit(root, "status", "--porcelain=v2", "--branch")
+	if err != nil {
+		return "", GitStatus{}
+	}
+	branch, st := parseGitStatus(out)
+	st.Submodules = uninitializedSubmodules(root)
+	return branch, st
+}
+
+// uninitializedSubmodules counts the "-" entries of `git submodule status`.
+// Repositories without .gitmodules are not asked at all.
+func uninitializedSubmodules(root string) int {
+	if _, err := os.Stat(filepath.Join(root, ".gitmodules")); err != nil {
+		return 0
+	}
+	out, err := runGit(root, "submodule", "status")
+	if err != nil {
+		WriteLog(fmt.Sprintf("Submodule status in %s: %v", root, err))
+		return 0
+	}
+	n := 0
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "-") {
+			n++
+		}
+	}
+	return n
+}
+
+// GitCacheTTL is how long git info of a repository is reused before git is asked again.
+const GitCacheTTL = 2 * time.Minute
+
+type gitCacheEntry struct {
+	branch string
+	status GitStatus
+	at     time.Time
+}
+
+var (
+	gitCacheMu sync.Mutex
+	gitCache   = make(map[string]gitCacheEntry) // pathKey(repo root) -> info
+)
+
+// cachedGitInfo is getGitInfo backed by a per-repository cache, so projects
+// sharing a repository cost a single git call per GitCacheTTL.
+func cachedGitInfo(startPath string) (string, GitStatus) {
+	root := findGitRoot(startPath)
+	if root == "" {
+		return "", GitStatus{}
+	}
+	key := pathKey(root)
+	gitCacheMu.Lock()
+	entry, ok := gitCache[key]
+	gitCacheMu.Unlock()
+	if ok && time.Since(entry.at) < GitCacheTTL {
+		return entry.branch, entry.status
+	}
+	branch, status := gitInfoAt(root)
+	gitCacheMu.Lock()
+	gitCache[key] = gitCacheEntry{branch: branch, status: status, at: time.Now()}
+	gitCacheMu.Unlock()
+	return branch, status
+}
+
+func invalidateGitCache(root string) {
+	gitCacheMu.Lock()
+	delete(gitCache, pathKey(root))
+	gitCacheMu.Unlock()
+}
+
+// listBranches returns the local branches of the repository at root.
+func listBranches(root string) ([]string, error) {
+	out, err := runGit(root, "branch", "--format=%(refname:short)")
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, b := range strings.Split(out, "\n") {
+		if b = strings.TrimSpace(b); b != "" {
+			branches = append(branches, b)
+		}
+	}
+	return branches, nil
+}
+
+var invalidBranchChars = regexp.MustCompile(`[\s~^:?*\[\\]|\.\.|@\{`)
+
+// validateBranchName applies the git ref naming rules that users hit most
+// often; git check-ref-format has the final word when the branch is created.
+func validateBranchName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("branch name is empty")
+	case invalidBranchChars.MatchString(name):
+		return fmt.Errorf("branch name contains forbidden characters (spaces, ~ ^ : ? * [ \\ .. @{)")
+	case strings.HasPrefix(name, "-") || strings.HasPrefix(name, "/"):
+		return fmt.Errorf("branch name cannot start with %q", name[:1])
+	case strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock"):
+		return fmt.Errorf("branch name cannot end with \"/\", \".\" or \".lock\"")
+	}
+	return nil
+}
+
+// createBranch runs git checkout -b name in root.
+func createBranch(root, name string) error {
+	if _, err := runGit(root, "check-ref-format", "--branch", name); err != nil {
+		return fmt.Errorf("invalid branch name %q", name)
+	}
+	if _, err := runGit(root, "checkout", "-b", name); err != nil {
+		return err
+	}
+	return nil
+}
+
+// errNoRemote is returned by remoteURL when the repository has no origin remote.
+var errNoRemote = errors.New("no origin remote")
+
+// remoteURL returns the origin URL of the repository holding startPath.
+func remoteURL(startPath string) (string, error) {
+	root := findGitRoot(startPath)
+	if root == "" {
+		return "", fmt.Errorf("not a git repository")
+	}
+	url, err := runGit(root, "remote", "get-url", "origin")
+	if err != nil {
+		if strings.Contains(err.Error(), "No such remote") {
+			return "", errNoRemote
+		}
+		return "", err
+	}
+	if url == "" {
+		return "", errNoRemote
+	}
+	return url, nil
+}
+
+// remoteWebURL turns a git remote URL into the repository's web page:
+// git@host:org/repo.git and ssh://git@host:22/org/repo.git become
+// https://host/org/repo, credentials are dropped from http(s) URLs.
+func remoteWebURL(remote string) (string, error) {
+	u := strings.TrimSpace(remote)
+	switch {
+	case strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://"):
+		scheme, rest, _ := strings.Cut(u, "://")
+		if at := strings.Index(rest, "@"); at >= 0 && at < strings.Index(rest+"/", "/") {
+			rest = rest[at+1:]
+		}
+		u = scheme + "://" + rest
+	case strings.HasPrefix(u, "ssh://") || strings.HasPrefix(u, "git://"):
+		_, rest, _ := strings.Cut(u, "://")
+		host, path, ok := strings.Cut(rest, "/")
+		if !ok {
+			return "", fmt.Errorf("unsupported remote URL %q", remote)
+		}
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		host, _, _ = strings.Cut(host, ":") // ssh port is not the web port
+		u = "https://" + host + "/" + path
+	case strings.Contains(u, ":") && !strings.Contains(u, "://") && strings.Index(u, ":") > 1: // not C:\repo
+		host, path, _ := strings.Cut(u, ":")
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		u = "https://" + host + "/" + strings.TrimPrefix(path, "/")
+	default:
+		return "", fmt.Errorf("remote %q is not a web repository", remote)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git"), nil
+}
+
+// StashMode selects what happens to local changes when switching branches.
+type StashMode int
+
+const (
+	StashNone StashMode = iota // plain checkout, git refuses if changes conflict
+	StashPop                   // stash, checkout, pop the changes onto the new branch
+	StashKeep                  // stash, checkout, leave the changes in the stash
+)
+
+// stashRef returns the commit of the newest stash entry, "" without one.
+func stashRef(root string) string {
+	out, err := runGit(root, "rev-parse", "-q", "--verify", "refs/stash")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// switchBranch checks out branch in root, optionally moving local changes
+// through the stash. Returns a human readable summary.
+func switchBranch(root, branch string, mode StashMode) (string, error) {
+	stashed := false
+	if mode != StashNone {
+		// git prints "No local changes to save" localized, compare the stash
+		// ref instead: an unchanged ref means nothing was stashed.
+		before := stashRef(root)
+		if _, err := runGit(root, "stash", "push", "--include-untracked", "-m", "LazyPLCNext: before checkout "+branch); err != nil {
+			return "", fmt.Errorf("stash failed: %w", err)
+		}
+		stashed = stashRef(root) != before
+	}
+	if _, err := runGit(root, "checkout", branch); err != nil {
+		if stashed {
+			if _, popErr := runGit(root, "stash", "pop"); popErr != nil {
+				return "", fmt.Errorf("checkout failed: %v; changes remain in the stash", err)
+			}
+		}
+		return "", fmt.Errorf("checkout failed: %w", err)
+	}
+	switch {
+	case !stashed:
+		return "Switched to " + branch, nil
+	case mode == StashKeep:
+		return "Switched to " + branch + ", changes kept in stash", nil
+	}
+	if _, err := runGit(root, "stash", "pop"); err != nil {
+		return "", fmt.Errorf("switched to %s, but applying stashed changes conflicted; resolve the conflicts manually (changes are still in the stash): %v", branch, err)
+	}
+	return "Switched to " + branch + ", local changes restored", nil
+}
+
+// projectDir is the folder git and other tools should run in for p.
+func projectDir(p ProjectInfo) string {
+	if p.Type == TypeFlat {
+		return p.Path
+	}
+	return filepath.Dir(p.Path)
+}
+
+// genericNames are folder/file names that say nothing about the project itself.
+var genericNames = map[string]bool{
+	"src": true, "source": true, "project": true, "projects": true, "plc": true,
+	"plcnext": true, "archive": true, "archives": true, "backup": true, "pcwex": true,
+	"export": true, "release": true, "solution": true,
+}
+
+// logicalProjectRoot walks up from dir (max 3 levels, same as findGitRoot) to
+// the folder containing .git. Without a repository the nearest folder with a
+// non-generic name is used.
+func logicalProjectRoot(dir string) string {
+	d := dir
+	for i := 0; i < 3; i++ {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	d = dir
+	for i := 0; i < 3 && genericNames[strings.ToLower(filepath.Base(d))]; i++ {
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return d
+}
+
+// pcwexDisplayName builds a readable name for an archive that may be nested
+// deep inside the project folder.
+func pcwexDisplayName(archivePath string) string {
+	base := strings.TrimSuffix(filepath.Base(archivePath), filepath.Ext(archivePath))
+	parent := filepath.Dir(archivePath)
+	root := logicalProjectRoot(parent)
+	rootName := filepath.Base(root)
+	if root == parent || strings.EqualFold(rootName, base) {
+		if genericNames[strings.ToLower(base)] {
+			return rootName
+		}
+		return base
+	}
+	if genericNames[strings.ToLower(base)] {
+		return rootName
+	}
+	return rootName + "/" + base
+}
+
+// SolutionFileNames mark a flat project folder. Matching is case-insensitive.
+var SolutionFileNames = []string{"Solution.xml", "solution.xml"}
+
+// hasSolutionFile reports whether dir contains one of SolutionFileNames in
+// any letter case (case-sensitive shares and WSL folders included).
+func hasSolutionFile(dir string) bool {
+	for _, name := range SolutionFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		for _, name := range SolutionFileNames {
+			if !e.IsDir() && strings.EqualFold(e.Name(), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ScanOptions controls how ScanProjects walks a work directory.
+type ScanOptions struct {
+	FollowSymlinks bool
+	// DeferArchiveVersions leaves .pcwex versions pending, they are filled in
+	// later by archiveVersionCmd so the list shows up without unzipping.
+	DeferArchiveVersions bool
+	// DeferGit leaves git info pending for gitInfoCmd.
+	DeferGit bool
+}
+
+func scanOptionsFromConfig(cfg Config) ScanOptions {
+	return ScanOptions{FollowSymlinks: cfg.FollowSymlinks, DeferArchiveVersions: true, DeferGit: true}
+}
+
+// ScanProjects walks root for projects. When ctx is done the walk stops and
+// the projects found so far are returned together with ctx.Err().
+func ScanProjects(ctx context.Context, root string, opts ScanOptions) ([]ProjectInfo, error) {
 	var projects []ProjectInfo
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	// Real paths of walked directories, guards against symlink cycles.
+	visited := make(map[string]bool)
+	markVisited := func(path string) bool {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return false
+		}
+		real = pathKey(real)
+		if visited[real] {
+			return false
+		}
+		visited[real] = true
+		return true
+	}
+
+	gitInfo := func(dir string) (string, GitStatus, bool) {
+		if opts.DeferGit {
+			return "", GitStatus{}, true
+		}
+		branch, status := getGitInfo(dir)
+		return branch, status, false
+	}
+
+	// Paths over MAX_PATH are logged once per scan, a deep tree has thousands.
+	longPaths, firstLong := 0, ""
+	defer func() {
+		if longPaths > 0 {
+			WriteLog(fmt.Sprintf("%d path(s) under %s exceed MAX_PATH (%d chars), first: %s", longPaths, root, MaxPath, firstLong))
+		}
+	}()
+
+	var visit fs.WalkDirFunc
+	// Link targets are walked with a trailing separator so WalkDir resolves
+	// the link instead of reporting it; those roots are already marked.
+	linkRoots := make(map[string]bool)
+	walkLink := func(path string) error {
+		dir := path + string(filepath.Separator)
+		linkRoots[dir] = true
+		return filepath.WalkDir(dir, visit)
+	}
+	visit = func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			return nil
 		}
+		linkRoot := linkRoots[path]
+		if linkRoot {
+			path = filepath.Clean(path)
+		}
+		if len(path) >= MaxPath {
+			if longPaths == 0 {
+				firstLong = path
+			}
+			longPaths++
+		}
+		if opts.FollowSymlinks && !linkRoot && d.Type()&(fs.ModeSymlink|fs.ModeIrregular) != 0 {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				if markVisited(path) {
+					if err := walkLink(path); ctx.Err() != nil {
+						return err
+					}
+				} else {
+					WriteLog("Skipping already visited link target: " + path)
+				}
+			}
+			return nil
+		}
 		if d.IsDir() {
+			if opts.FollowSymlinks && !linkRoot && path != root && !markVisited(path) {
+				return filepath.SkipDir
+			}
 			name := strings.ToLower(d.Name())
 			if strings.HasPrefix(name, ".") || name == "bin" || name == "obj" {
 				return filepath.SkipDir
 			}
-			if _, err := os.Stat(filepath.Join(path, "Solution.xml")); err == nil {
-				ver := extractVersionFromFolder(path)
-				branch := getGitBranch(path)
+			if hasSolutionFile(path) {
+				ver, mixed := extractVersionFromFolder(path)
+				branch, gitStatus, gitPending := gitInfo(path)
 				projects = append(projects, ProjectInfo{
-					Name: d.Name(), Path: path, Type: TypeFlat, Version: ver, GitBranch: branch,
+					Name: d.Name(), Path: path, Type: TypeFlat, Version: ver, GitBranch: branch, Git: gitStatus, GitPending: gitPending,
+					MixedVersions: mixed,
 				})
 				return filepath.SkipDir
 			}
@@ -396,14 +1620,17 @@ func ScanProjects(root string) []ProjectInfo {
 		lowerName := strings.ToLower(name)
 
 		if strings.HasSuffix(lowerName, ".pcwex") {
-			ver, _ := extractVersionFromZip(path)
-			if ver == "" {
-				ver = "Unknown"
+			ver, pending, broken, flatVer := "", true, false, ""
+			if !opts.DeferArchiveVersions {
+				ver, broken = archiveVersionStatus(path)
+				flatVer = flatVersionMismatch(path, ver)
+				pending = false
 			}
 			parentDir := filepath.Dir(path)
-			branch := getGitBranch(parentDir)
+			branch, gitStatus, gitPending := gitInfo(parentDir)
 			projects = append(projects, ProjectInfo{
-				Name: strings.TrimSuffix(name, filepath.Ext(name)), Path: path, Type: TypePCWEX, Version: ver, GitBranch: branch,
+				Name: pcwexDisplayName(path), Path: path, Type: TypePCWEX, Version: ver, GitBranch: branch, Git: gitStatus, GitPending: gitPending,
+				VersionPending: pending, Broken: broken, FlatVersion: flatVer,
 			})
 			return nil
 		}
@@ -412,439 +1639,3850 @@ func ScanProjects(root string) []ProjectInfo {
 			baseName := strings.TrimSuffix(name, filepath.Ext(name))
 			flatFolder := filepath.Join(filepath.Dir(path), baseName+"Flat")
 			ver := "Unknown"
+			var mixed []string
 			if _, err := os.Stat(flatFolder); err == nil {
-				ver = extractVersionFromFolder(flatFolder)
+				ver, mixed = extractVersionFromFolder(flatFolder)
 			}
 			parentDir := filepath.Dir(path)
-			branch := getGitBranch(parentDir)
+			branch, gitStatus, gitPending := gitInfo(parentDir)
 			projects = append(projects, ProjectInfo{
-				Name: baseName, Path: path, Type: TypePCWEF, Version: ver, IsPCWEF: true, GitBranch: branch,
+				Name: baseName, Path: path, Type: TypePCWEF, Version: ver, IsPCWEF: true, GitBranch: branch, Git: gitStatus, GitPending: gitPending,
+				MixedVersions: mixed,
 			})
 			return nil
 		}
 		return nil
-	})
-	if err != nil {
+	}
+	if opts.FollowSymlinks {
+		markVisited(root)
+	}
+	if err := filepath.WalkDir(root, visit); err != nil {
+		if ctx.Err() != nil {
+			WriteLog(fmt.Sprintf("Scan of %s aborted: %v (%d projects found so far)", root, err, len(projects)))
+			return projects, ctx.Err()
+		}
 		WriteLog(fmt.Sprintf("Scan error: %v", err))
 	}
-	return projects
+	return projects, nil
 }
 
-func FindInstalledIDEs() map[string]string {
-	versions := make(map[string]string)
-	entries, err := os.ReadDir(IDEBasePath)
-	if err != nil {
-		return versions
+// scanDir checks that dir is a reachable folder and scans it, returning when
+// ctx is done even if a file system call is stuck (a dead network share can
+// block Stat or ReadDir far past the timeout). The abandoned walk finishes in
+// the background. A timeout is reported as ctx.Err().
+func scanDir(ctx context.Context, dir string, opts ScanOptions) ([]ProjectInfo, error) {
+	type result struct {
+		projects []ProjectInfo
+		err      error
 	}
-	re := regexp.MustCompile(`PLCnext Engineer (\d+(\.\d+)+)`)
-	exeNames := []string{"PLCNENG64.exe", "PLCnextEngineer.exe"}
-	for _, e := range entries {
-		if e.IsDir() && re.MatchString(e.Name()) {
-			matches := re.FindStringSubmatch(e.Name())
-			ver := matches[1]
-			for _, exe := range exeNames {
-				fullExe := filepath.Join(IDEBasePath, e.Name(), exe)
-				if _, err := os.Stat(fullExe); err == nil {
-					versions[ver] = fullExe
-					break
-				}
-			}
+	done := make(chan result, 1)
+	go func() {
+		info, err := os.Stat(dir)
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("%s is not a directory", dir)
+		}
+		if err != nil {
+			done <- result{nil, err}
+			return
 		}
+		projects, err := ScanProjects(ctx, dir, opts)
+		done <- result{projects, err}
+	}()
+	select {
+	case r := <-done:
+		return r.projects, r.err
+	case <-ctx.Done():
+		WriteLog(fmt.Sprintf("Scan of %s did not stop in time, the share may be unreachable", dir))
+		return nil, ctx.Err()
 	}
-	return versions
 }
 
-func GetRunningIDE(targetVer string) (string, int32, bool) {
-	procs, _ := process.Processes()
-	for _, p := range procs {
-		name, _ := p.Name()
-		if strings.Contains(name, "PLCNENG64") || strings.Contains(name, "PLCnextEngineer") {
-			exePath, _ := p.Exe()
-			dir := filepath.Base(filepath.Dir(exePath))
-			re := regexp.MustCompile(`(\d+(\.\d+)+)`)
-			match := re.FindString(dir)
-			if match == targetVer {
-				return exePath, p.Pid, true
+// DefaultLockFilePatterns are the lock files PLCnext Engineer leaves next to
+// an opened project. {name} is the project file (or folder) name, {base} the
+// same without extension. Override with Config.LockFilePatterns.
+var DefaultLockFilePatterns = []string{"{name}.lock", "{base}.lock", "{name}.lck", "{base}.lck", "~${base}*", "*.lock", "*.lck"}
+
+// globEscape makes s match literally in a glob pattern. '*' and '?' cannot
+// occur in Windows file names, only '[' needs escaping.
+var globEscape = strings.NewReplacer("[", "[[]")
+
+// findProjectLock looks for lock files of proj and returns the first match.
+// Next to archives, in a folder shared with other projects, only patterns
+// naming the project are used; inside the project's own folders all apply.
+func findProjectLock(proj ProjectInfo, patterns []string) (string, bool) {
+	if len(patterns) == 0 {
+		patterns = DefaultLockFilePatterns
+	}
+	name := filepath.Base(proj.Path)
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	expand := strings.NewReplacer("{name}", globEscape.Replace(name), "{base}", globEscape.Replace(base))
+	find := func(dir string, own bool) (string, bool) {
+		for _, pattern := range patterns {
+			if !own && !strings.Contains(pattern, "{name}") && !strings.Contains(pattern, "{base}") {
+				continue
+			}
+			matches, err := filepath.Glob(filepath.Join(globEscape.Replace(dir), expand.Replace(pattern)))
+			if err == nil && len(matches) > 0 {
+				return matches[0], true
 			}
 		}
+		return "", false
 	}
-	return "", 0, false
-}
-
-// ======================================================================================
-// UI: CUSTOM LIST DELEGATE
-// ======================================================================================
-
-type projectDelegate struct {
-	UseNerdFonts bool
+	if proj.Type != TypeFlat {
+		if lock, found := find(filepath.Dir(proj.Path), false); found {
+			return lock, true
+		}
+	}
+	for _, f := range projectFiles(proj) {
+		if info, err := os.Stat(f); err == nil && info.IsDir() {
+			if lock, found := find(f, true); found {
+				return lock, true
+			}
+		}
+	}
+	return "", false
 }
 
-func (d projectDelegate) Height() int                             { return 2 }
-func (d projectDelegate) Spacing() int                            { return 1 }
-func (d projectDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
-func (d projectDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
-	p, ok := listItem.(ProjectInfo)
-	if !ok {
-		return
-	}
+// DefaultCleanDirs are the build output folders removed by clean (key X).
+// Override with Config.CleanDirs.
+var DefaultCleanDirs = []string{"bin", "obj"}
 
-	icon := "📦"
-	typeLabel := "PCWEX"
+// flatFolder returns the folder holding the flat project files of p: the
+// project itself, or the "<name>Flat" folder of a .pcwef.
+func flatFolder(p ProjectInfo) (string, bool) {
 	switch p.Type {
 	case TypeFlat:
-		icon = "📂"
-		typeLabel = "DIR"
+		return p.Path, true
 	case TypePCWEF:
-		icon = "🔗"
-		typeLabel = "PCWEF"
+		folder := strings.TrimSuffix(p.Path, filepath.Ext(p.Path)) + "Flat"
+		if info, err := os.Stat(folder); err == nil && info.IsDir() {
+			return folder, true
+		}
 	}
+	return "", false
+}
 
-	verBadge := verBadgeStyle.Render(fmt.Sprintf("v%s", p.Version))
-	typeBadge := typeBadgeStyle.Render(typeLabel)
-
-	var gitBadge string
-	if p.GitBranch != "" {
-		bName := p.GitBranch
-		if len(bName) > 15 {
-			bName = bName[:12] + "..."
+// cleanTargets finds the direct subfolders of folder named like one of names
+// (case-insensitive) and returns them with their total size. Folders holding
+// git-tracked files are source, not build output, and are left alone.
+func cleanTargets(folder string, names []string) ([]string, uint64) {
+	if len(names) == 0 {
+		names = DefaultCleanDirs
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, 0
+	}
+	var dirs []string
+	var size uint64
+	for _, e := range entries {
+		if !e.IsDir() || !slices.ContainsFunc(names, func(name string) bool { return strings.EqualFold(e.Name(), name) }) {
+			continue
 		}
-		gitIcon := ""
-		if d.UseNerdFonts {
-			gitIcon = " "
+		if gitAvailable() {
+			if out, err := runGit(folder, "ls-files", "--", e.Name()); err == nil && out != "" {
+				WriteLog("Clean skipped git-tracked folder: " + filepath.Join(folder, e.Name()))
+				continue
+			}
 		}
-		gitBadge = gitBadgeStyle.Render(gitIcon + bName)
+		path := filepath.Join(folder, e.Name())
+		dirs = append(dirs, path)
+		size += pathSize(path)
 	}
+	return dirs, size
+}
 
-	var (
-		titleRes string
-		descRes  string
-	)
+// BackupDirName is the folder next to the executable where project backups are stored.
+const BackupDirName = "backups"
 
-	displayPath := p.Path
-	if len(displayPath) > 60 {
-		displayPath = "..." + displayPath[len(displayPath)-57:]
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
 	}
-
-	if index == m.Index() {
-		titleRes = selectedItemStyle.Render(fmt.Sprintf("%s %s", icon, p.Name))
-		badges := lipgloss.JoinHorizontal(lipgloss.Left, typeBadge, gitBadge, verBadge)
-		descRes = selectedItemStyle.Copy().UnsetBorderStyle().Render(
-			fmt.Sprintf("%s\n%s", badges, displayPath),
-		)
-	} else {
-		titleRes = itemTitleStyle.Render(fmt.Sprintf("%s %s", icon, p.Name))
-		badges := lipgloss.JoinHorizontal(lipgloss.Left, typeBadge, gitBadge, verBadge)
-		descRes = fmt.Sprintf("   %s\n   %s", badges, itemDescStyle.Render(displayPath))
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
 	}
-
-	fmt.Fprint(w, titleRes+"\n"+descRes)
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
 
-// ======================================================================================
-// TEA MODEL
-// ======================================================================================
-
-type AppState int
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
 
-const (
-	StateConfig AppState = iota
-	StateList
-	StateLaunching
-	StateSuccess
-	StateError
-	StateUpdateFound
-	StateUpdating
-)
+// projectFiles lists everything that belongs to a project on disk: the archive,
+// the flat folder, or the .pcwef file together with its "<name>Flat" folder.
+func projectFiles(proj ProjectInfo) []string {
+	files := []string{proj.Path}
+	if proj.Type == TypePCWEF {
+		baseName := strings.TrimSuffix(filepath.Base(proj.Path), filepath.Ext(proj.Path))
+		flatFolder := filepath.Join(filepath.Dir(proj.Path), baseName+"Flat")
+		if _, err := os.Stat(flatFolder); err == nil {
+			files = append(files, flatFolder)
+		}
+	}
+	return files
+}
 
-type model struct {
-	state       AppState
-	config      Config
-	list        list.Model
-	textInput   textinput.Model
-	spinner     spinner.Model
-	logMsg      string
-	selectedPrj ProjectInfo
-	err         error
-	width       int
-	height      int
-	updateVer   string
-	updateURL   string
-	directMode  bool // true when launched with a CLI path argument — list is never initialized
-}
-
-func initialModel(directProj *ProjectInfo) model {
-	ti := textinput.New()
-	ti.Placeholder = "C:\\PhoenixProjects"
-	ti.Focus()
-	ti.CharLimit = 256
-	ti.Width = 50
-	ti.PromptStyle = focusedInputStyle
-	ti.TextStyle = focusedInputStyle
+// pathSize returns the size of a file or the total size of a folder tree.
+func pathSize(path string) uint64 {
+	var size uint64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += uint64(info.Size())
+			}
+		}
+		return nil
+	})
+	return size
+}
 
-	sp := spinner.New()
-	sp.Spinner = spinner.Dot
-	sp.Style = lipgloss.NewStyle().Foreground(colPrimary)
+// FreeSpaceMargin is kept free on top of the estimated size of a write.
+const FreeSpaceMargin = 100 << 20
 
-	m := model{
-		state:     StateConfig,
-		textInput: ti,
-		spinner:   sp,
+// ensureFreeSpace fails when the volume holding dir has less than need bytes
+// (plus FreeSpaceMargin) available. Without a way to query it, it passes.
+func ensureFreeSpace(dir string, need uint64) error {
+	free, err := diskFree(dir)
+	if err != nil {
+		if !errors.Is(err, errWindowsOnly) {
+			WriteLog(fmt.Sprintf("Free space check skipped: %v", err))
+		}
+		return nil
+	}
+	if free < need+FreeSpaceMargin {
+		WriteLog(fmt.Sprintf("Refused: not enough free space in %s (need %s, available %s)", dir, humanizeBytes(need), humanizeBytes(free)))
+		return fmt.Errorf("not enough free space in %s: need about %s, available %s", dir, humanizeBytes(need), humanizeBytes(free))
 	}
+	return nil
+}
 
-	if directProj != nil {
-		m.selectedPrj = *directProj
-		m.state = StateLaunching
-		m.directMode = true
-		return m
+// backupProject copies the project into backups\<name>_<timestamp> next to the
+// executable and returns the backup folder.
+func backupProject(proj ProjectInfo) (string, error) {
+	exePath, _ := os.Executable()
+	name := invalidFileNameChars.ReplaceAllString(proj.Name, "_")
+	dest := filepath.Join(filepath.Dir(exePath), BackupDirName, name+"_"+time.Now().Format("20060102_150405"))
+	var need uint64
+	for _, src := range projectFiles(proj) {
+		need += pathSize(src)
+	}
+	if err := ensureFreeSpace(filepath.Dir(exePath), need); err != nil {
+		return "", err
+	}
+	for _, src := range projectFiles(proj) {
+		info, err := os.Stat(src)
+		if err == nil {
+			target := filepath.Join(dest, filepath.Base(src))
+			if info.IsDir() {
+				err = copyDir(src, target)
+			} else {
+				err = copyFile(src, target)
+			}
+		}
+		if err != nil {
+			// A partial backup is worse than none: it looks complete in the folder.
+			if rmErr := os.RemoveAll(dest); rmErr == nil {
+				WriteLog("Removed incomplete backup: " + dest)
+			}
+			return "", err
+		}
 	}
+	return dest, nil
+}
 
-	cfg, err := loadConfig()
-	if err == nil && len(cfg.WorkDirs) > 0 {
-		if _, err := os.Stat(cfg.WorkDirs[0]); err == nil {
-			m.config = cfg
-			m.state = StateList
-			m.reloadList()
+func FindInstalledIDEs() map[string]string {
+	versions := make(map[string]string)
+	entries, err := os.ReadDir(IDEBasePath)
+	if err != nil {
+		return versions
+	}
+	exeNames := []string{"PLCNENG64.exe", "PLCnextEngineer.exe"}
+	for _, e := range entries {
+		if e.IsDir() && ideFolderRe.MatchString(e.Name()) {
+			matches := ideFolderRe.FindStringSubmatch(e.Name())
+			ver := matches[1]
+			for _, exe := range exeNames {
+				fullExe := filepath.Join(IDEBasePath, e.Name(), exe)
+				if _, err := os.Stat(fullExe); err == nil {
+					versions[ver] = fullExe
+					break
+				}
+			}
 		}
 	}
+	return versions
+}
 
-	return m
+// compareVersions compares dotted numeric versions ("2023.0.1" vs "2022.6").
+// Returns -1, 0 or 1. Non-numeric parts compare as 0.
+func compareVersions(a, b string) int {
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			fmt.Sscanf(pa[i], "%d", &na)
+		}
+		if i < len(pb) {
+			fmt.Sscanf(pb[i], "%d", &nb)
+		}
+		if na < nb {
+			return -1
+		}
+		if na > nb {
+			return 1
+		}
+	}
+	return 0
 }
 
-func (m *model) reloadList() {
-	if len(m.config.WorkDirs) == 0 {
-		return
+// majorMinor returns the first two components of a dotted version ("2024.0.3" -> "2024.0").
+func majorMinor(v string) string {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return v
 	}
-	projects := ScanProjects(m.config.WorkDirs[0])
+	return parts[0] + "." + parts[1]
+}
 
-	sort.Slice(projects, func(i, j int) bool {
-		if projects[i].Type == TypeFlat && projects[j].Type != TypeFlat {
+// hasMatchingIDE reports whether an installed IDE matches ver at least by
+// major.minor. Unknown versions are not flagged since they cannot be matched.
+func hasMatchingIDE(installed map[string]string, ver string) bool {
+	if ver == "" || ver == "Unknown" {
+		return true
+	}
+	want := majorMinor(ver)
+	for v := range installed {
+		if majorMinor(v) == want {
 			return true
 		}
-		if projects[i].Type != TypeFlat && projects[j].Type == TypeFlat {
-			return false
-		}
-		return strings.ToLower(projects[i].Name) < strings.ToLower(projects[j].Name)
+	}
+	return false
+}
+
+// sortedIDEVersions returns installed versions ordered from newest to oldest.
+func sortedIDEVersions(installed map[string]string) []string {
+	keys := make([]string, 0, len(installed))
+	for k := range installed {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return compareVersions(keys[i], keys[j]) > 0
 	})
+	return keys
+}
 
-	items := make([]list.Item, len(projects))
-	for i, p := range projects {
-		items[i] = p
+// selectIDE picks the IDE executable for targetVer. Falls back to defaultVer
+// (if installed) and then to the newest installed version. The returned rule
+// describes which of these was applied.
+func selectIDE(installed map[string]string, targetVer, defaultVer string) (string, string, bool) {
+	if path, ok := installed[targetVer]; ok {
+		return path, "exact match", true
+	}
+	if defaultVer != "" {
+		if path, ok := installed[defaultVer]; ok {
+			return path, "default version " + defaultVer, true
+		}
+		WriteLog(fmt.Sprintf("Default IDE version %s is not installed, ignoring", defaultVer))
+	}
+	if keys := sortedIDEVersions(installed); len(keys) > 0 {
+		return installed[keys[0]], "latest installed " + keys[0], true
 	}
+	return "", "", false
+}
 
-	delegate := projectDelegate{UseNerdFonts: m.config.UseNerdFonts}
-	l := list.New(items, delegate, 0, 0)
-	l.Title = "PLCnext Projects"
-	l.SetShowHelp(false)
-	l.Styles.Title = titleStyle
-	l.Styles.PaginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
+// RunningIDE is a running PLCnext Engineer process.
+type RunningIDE struct {
+	PID     int32
+	Version string // from the installation folder, e.g. "2024.0.3"
+	Exe     string
+	Project string // project path passed on the command line, if any
+}
 
-	l.AdditionalFullHelpKeys = func() []key.Binding {
-		return []key.Binding{
-			key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "change path")),
-			key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "launch")),
+// ideFolderRe matches the IDE install folders under IDEBasePath.
+var ideFolderRe = regexp.MustCompile(`PLCnext Engineer (\d+(\.\d+)+)`)
+
+var ideVersionRe = regexp.MustCompile(`(\d+(\.\d+)+)`)
+
+// ideVersionFromPath extracts the version from an IDE executable path
+// (...\PLCnext Engineer 2024.0.3\PLCNENG64.exe).
+func ideVersionFromPath(exePath string) string {
+	return ideVersionRe.FindString(filepath.Base(filepath.Dir(exePath)))
+}
+
+// RunningIDEs lists all running PLCnext Engineer instances.
+func RunningIDEs() []RunningIDE {
+	var result []RunningIDE
+	procs, _ := process.Processes()
+	for _, p := range procs {
+		name, _ := p.Name()
+		if !strings.Contains(name, "PLCNENG64") && !strings.Contains(name, "PLCnextEngineer") {
+			continue
+		}
+		exePath, _ := p.Exe()
+		ide := RunningIDE{PID: p.Pid, Version: ideVersionFromPath(exePath), Exe: exePath}
+		if args, err := p.CmdlineSlice(); err == nil {
+			for _, a := range args[min(1, len(args)):] {
+				if !strings.HasPrefix(a, "/") && !strings.HasPrefix(a, "-") {
+					ide.Project = a
+				}
+			}
 		}
+		result = append(result, ide)
 	}
+	return result
+}
 
-	m.list = l
-	m.state = StateList
-	if m.width > 0 {
-		m.list.SetSize(m.width, m.height-2)
+func GetRunningIDE(targetVer string) (string, int32, bool) {
+	for _, ide := range RunningIDEs() {
+		if ide.Version == targetVer {
+			return ide.Exe, ide.PID, true
+		}
 	}
+	return "", 0, false
 }
 
-type tickMsg time.Time
-
-type updateCheckMsg struct {
-	version string
-	url     string
-	err     error
+// runningSummary renders "v2024.0.3 ×2, v2023.0" for the status line.
+func runningSummary(running []RunningIDE) string {
+	counts := make(map[string]int)
+	var versions []string
+	for _, r := range running {
+		if counts[r.Version] == 0 {
+			versions = append(versions, r.Version)
+		}
+		counts[r.Version]++
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareVersions(versions[i], versions[j]) > 0 })
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = "v" + v
+		if counts[v] > 1 {
+			parts[i] += fmt.Sprintf(" ×%d", counts[v])
+		}
+	}
+	return strings.Join(parts, ", ")
 }
-type updateDoneMsg struct{ err error }
 
-func checkUpdateCmd() tea.Cmd {
+// DiskFreeRefresh is how often free space of the project volumes is re-read.
+const DiskFreeRefresh = 30 * time.Second
+
+// LowDiskSpace marks a volume as critically full in the UI.
+const LowDiskSpace = 2 << 30
+
+type diskFreeMsg struct{ free map[string]uint64 }
+
+type diskRefreshMsg struct{}
+
+// diskFreeCmd reads the free space of every volume holding a work dir or a
+// listed project.
+func (m model) diskFreeCmd() tea.Cmd {
+	paths := append([]string(nil), m.config.WorkDirs...)
+	for _, it := range m.list.Items() {
+		if p, ok := it.(ProjectInfo); ok {
+			paths = append(paths, p.Path)
+		}
+	}
 	return func() tea.Msg {
-		ver, url, err := checkUpdate()
-		return updateCheckMsg{version: ver, url: url, err: err}
+		free := make(map[string]uint64)
+		for _, p := range paths {
+			vol := filepath.VolumeName(p)
+			if vol == "" {
+				continue
+			}
+			if _, done := free[vol]; done {
+				continue
+			}
+			if n, err := diskFree(vol + `\`); err == nil {
+				free[vol] = n
+			}
+		}
+		return diskFreeMsg{free: free}
 	}
 }
 
-func waitForNextUpdateCheck() tea.Cmd {
-	return tea.Tick(UpdateCheckInterval, func(t time.Time) tea.Msg {
-		return tickMsg(t)
-	})
+// driveKind tells where path lives: "network" (mapped drive or UNC share),
+// "removable", "cloud" (inside the OneDrive folder) or "" for a local disk.
+// Projects outside a local disk open slowly and are prone to lock problems.
+func driveKind(path string) string {
+	if inOneDrive(path) {
+		return "cloud"
+	}
+	vol := filepath.VolumeName(path)
+	if strings.HasPrefix(vol, `\\`) {
+		return "network"
+	}
+	if vol == "" {
+		return ""
+	}
+	return volumeKind(vol + `\`)
 }
 
-func performUpdateCmd(url string) tea.Cmd {
-	return func() tea.Msg {
-		err := doUpdate(url)
-		return updateDoneMsg{err: err}
+// inOneDrive reports whether path is inside the user's OneDrive folder.
+func inOneDrive(path string) bool {
+	od := os.Getenv("OneDrive")
+	if od == "" {
+		return false
 	}
+	rel, err := filepath.Rel(od, path)
+	return err == nil && !strings.HasPrefix(rel, "..")
 }
 
-func (m model) Init() tea.Cmd {
-	cmds := []tea.Cmd{
-		textinput.Blink,
-		checkUpdateCmd(),
-		waitForNextUpdateCheck(),
+// driveKindLabel is the marker shown next to the project path.
+func driveKindLabel(kind string) string {
+	switch kind {
+	case "network":
+		return "⚠ network drive"
+	case "removable":
+		return "⚠ removable drive"
+	case "cloud":
+		return "⚠ OneDrive"
 	}
-	if m.state == StateLaunching {
-		cmds = append(cmds, m.spinner.Tick, launchProjectCmd(m.selectedPrj))
-	}
-	return tea.Batch(cmds...)
+	return ""
 }
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmd tea.Cmd
+// diskFreeText renders "D: 12.3 GB free" for the volume of path, flagged when
+// space is critically low. Empty when unknown.
+func (m model) diskFreeText(path string) string {
+	vol := filepath.VolumeName(path)
+	free, ok := m.diskFree[vol]
+	if !ok {
+		return ""
+	}
+	text := fmt.Sprintf("%s %s free", vol, humanizeBytes(free))
+	if free < LowDiskSpace {
+		return warnTextStyle.Render("⚠ " + text)
+	}
+	return text
+}
 
-	switch msg := msg.(type) {
-	case tea.WindowSizeMsg:
-		m.width, m.height = msg.Width, msg.Height
-		docStyle = docStyle.MaxWidth(m.width).MaxHeight(m.height)
-		if m.state == StateList {
-			m.list.SetSize(msg.Width-4, msg.Height-4)
-		}
+// RunningIDERefresh is how often running IDE instances are re-listed.
+const RunningIDERefresh = 10 * time.Second
 
-	case tickMsg:
-		return m, tea.Batch(checkUpdateCmd(), waitForNextUpdateCheck())
+type runningIDEsMsg struct{ running []RunningIDE }
 
-	case updateCheckMsg:
-		if msg.err == nil && msg.version != "" {
-			if m.state != StateLaunching && m.state != StateUpdating && m.state != StateUpdateFound {
-				m.updateVer = msg.version
-				m.updateURL = msg.url
-				m.state = StateUpdateFound
-			}
-		}
+func runningIDEsCmd() tea.Cmd {
+	return func() tea.Msg { return runningIDEsMsg{running: RunningIDEs()} }
+}
 
-	case updateDoneMsg:
-		if msg.err != nil {
-			m.err = msg.err
-			m.state = StateError
-		} else {
-			m.logMsg = "Update successful! Please restart."
-			m.state = StateSuccess
-		}
+func runningIDEsTimer() tea.Cmd {
+	return tea.Tick(RunningIDERefresh, func(time.Time) tea.Msg { return runningIDEsCmd()() })
+}
 
-	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" {
-			return m, tea.Quit
-		}
-		if m.state == StateList && msg.String() == "q" && m.list.FilterState() != list.Filtering {
-			return m, tea.Quit
-		}
+// gitSyncMarker summarises the repository state for the git badge: a check
+// when clean and in sync, a dot for local changes, arrows for ahead/behind and
+// a warning for submodules that are not initialized.
+func gitSyncMarker(st GitStatus, nerdFonts bool) string {
+	check, dirty, up, down, warn := "✔", "●", "↑", "↓", "⚠"
+	if nerdFonts {
+		check, dirty, up, down, warn = "\uf00c", "\uf111", "\uf062", "\uf063", "\uf071"
+	}
+	var parts []string
+	if st.Submodules > 0 {
+		parts = append(parts, markerWarnStyle.Render(fmt.Sprintf("%s%d", warn, st.Submodules)))
+	}
+	if st.Dirty {
+		parts = append(parts, markerDirtyStyle.Render(dirty))
+	}
+	if st.Ahead > 0 {
+		parts = append(parts, markerSyncStyle.Render(fmt.Sprintf("%s%d", up, st.Ahead)))
+	}
+	if st.Behind > 0 {
+		parts = append(parts, markerSyncStyle.Render(fmt.Sprintf("%s%d", down, st.Behind)))
+	}
+	if len(parts) == 0 && st.Upstream {
+		parts = append(parts, markerCleanStyle.Render(check))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, " ") + " "
+}
 
-		if m.state == StateSuccess {
-			if strings.Contains(m.logMsg, "Update successful") && (msg.String() == "r" || msg.String() == "R") {
-				restartApp()
-				return m, tea.Quit
+// missingPaths returns the paths that no longer exist on disk. A path whose
+// Stat does not return before ctx is done (dead network share) counts as
+// present.
+func missingPaths(ctx context.Context, paths []string) []string {
+	results := make(chan string, len(paths))
+	for _, path := range paths {
+		go func() {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				results <- path
+			} else {
+				results <- ""
 			}
-			switch msg.String() {
-			case "esc", "enter", "q", " ":
-				if m.directMode {
-					return m, tea.Quit
-				}
-				m.state = StateList
-				return m, nil
+		}()
+	}
+	var missing []string
+	for range paths {
+		select {
+		case path := <-results:
+			if path != "" {
+				missing = append(missing, path)
 			}
+		case <-ctx.Done():
+			WriteLog("History check did not finish in time, unreachable projects are kept")
+			return missing
 		}
 	}
+	return missing
+}
 
-	switch m.state {
-	case StateUpdateFound:
-		if key, ok := msg.(tea.KeyMsg); ok {
-			switch key.String() {
-			case "y", "Y", "enter":
-				m.state = StateUpdating
+// humanizeBytes formats n as "512 B", "1.5 KB", "12.3 MB".
+func humanizeBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// MaxPreviewEntries limits the .pcwex preview list.
+const MaxPreviewEntries = 500
+
+// archiveListing lists the files of a .pcwex with their sizes, reading only
+// the zip directory.
+func archiveListing(path string) (string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	var b strings.Builder
+	var total uint64
+	for i, f := range r.File {
+		total += f.UncompressedSize64
+		if i >= MaxPreviewEntries {
+			continue
+		}
+		fmt.Fprintf(&b, "%10s  %s\n", humanizeBytes(f.UncompressedSize64), f.Name)
+	}
+	if n := len(r.File) - MaxPreviewEntries; n > 0 {
+		fmt.Fprintf(&b, "… and %d more files\n", n)
+	}
+	fmt.Fprintf(&b, "\n%d files, %s uncompressed", len(r.File), humanizeBytes(total))
+	return b.String(), nil
+}
+
+// previewMsg is the archiveListing of path for StatePreview.
+type previewMsg struct {
+	path    string
+	content string
+	err     error
+}
+
+// previewCmd reads the listing in the background, a large archive on a
+// network drive takes a while.
+func previewCmd(path string) tea.Cmd {
+	return func() tea.Msg {
+		content, err := archiveListing(path)
+		return previewMsg{path: path, content: content, err: err}
+	}
+}
+
+// humanizeSince formats t relative to now ("today", "3 days ago").
+func humanizeSince(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d min ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d h ago", int(d.Hours()))
+	case d < 48*time.Hour:
+		return "yesterday"
+	}
+	return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+}
+
+// ======================================================================================
+// UI: CUSTOM LIST DELEGATE
+// ======================================================================================
+
+type projectDelegate struct {
+	UseNerdFonts bool
+	LaunchTimes  map[string]time.Time
+	LaunchCounts map[string]int
+	InstalledIDE map[string]string // FindInstalledIDEs at scan time, for the "no IDE" badge
+	Marked       map[string]bool   // shared with model.marked
+
+	ProblemSection bool              // problem rows show their reason (Config.ProblemSection)
+	DriveKinds     map[string]string // volume -> driveKind, for the network drive marker
+	IconRules      []iconRule        // compiled Config.IconRules, override the type icon
+}
+
+func (d projectDelegate) Height() int                             { return 3 } // title, badges, path
+func (d projectDelegate) Spacing() int                            { return 1 }
+func (d projectDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+func (d projectDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	if h, ok := listItem.(groupHeader); ok {
+		title := groupTitleStyle.Render("v" + h.Title)
+		switch {
+		case h.Problems:
+			title = problemTitleStyle.Render("⚠ " + h.Title)
+		case h.Title == "Unknown":
+			title = groupTitleStyle.Render(h.Title)
+		}
+		fmt.Fprintf(w, " %s %s\n %s\n", title, subTextStyle.Render(fmt.Sprintf("(%d)", h.Count)),
+			subTextStyle.Render(strings.Repeat("─", max(0, min(m.Width()-2, 40)))))
+		return
+	}
+	p, ok := listItem.(ProjectInfo)
+	if !ok {
+		return
+	}
+
+	icon := p.Type.Icon(d.UseNerdFonts)
+	if custom := ruleIcon(d.IconRules, p); custom != "" {
+		icon = custom
+	}
+	typeLabel := p.Type.Label()
+
+	verBadge := verBadgeStyle.Render(fmt.Sprintf("v%s", p.Version))
+	if p.VersionPending {
+		verBadge = verBadgeStyle.Render("v…")
+	} else if len(p.MixedVersions) > 0 {
+		verBadge += warnBadgeStyle.Render("mixed")
+	} else if p.FlatVersion != "" {
+		verBadge += warnBadgeStyle.Render("flat v" + p.FlatVersion)
+	}
+	if d.ProblemSection {
+		// Rows of the problem section carry the reason they are there.
+		if reason := projectProblem(p, d.InstalledIDE); reason != "" {
+			verBadge = verBadgeStyle.Render(fmt.Sprintf("v%s", p.Version)) + warnBadgeStyle.Render(reason)
+		}
+	} else if !p.VersionPending && !hasMatchingIDE(d.InstalledIDE, p.Version) {
+		verBadge += warnBadgeStyle.Render("no IDE")
+	}
+	typeBadge := typeBadgeStyle.Render(typeLabel)
+
+	// Available cells after the 3-column indent / selection border.
+	avail := m.Width() - 3
+	if avail < 10 {
+		avail = 10
+	}
+
+	var gitBadge string
+	if p.GitBranch != "" {
+		gitIcon := ""
+		if d.UseNerdFonts {
+			gitIcon = "\ue0a0 "
+		}
+		gitBadge = gitBadgeStyle.Render(gitIcon+truncate(p.GitBranch, min(15, avail/3))) + gitSyncMarker(p.Git, d.UseNerdFonts)
+	}
+
+	var lastRun string
+	if t, ok := d.LaunchTimes[p.Path]; ok {
+		lastRun = subTextStyle.Render(fmt.Sprintf("launched %s (%d×)", humanizeSince(t), d.LaunchCounts[p.Path]))
+	}
+
+	var tagBadges string
+	if p.Archived {
+		tagBadges += tagBadgeStyle.Render("archived")
+	}
+	for _, tag := range p.Tags {
+		tagBadges += tagBadgeStyle.Render("#" + tag)
+	}
+
+	// Drop secondary badges (least important first) until the row fits.
+	parts := []string{typeBadge, gitBadge, verBadge, tagBadges, lastRun}
+	for _, drop := range []int{4, 3, 1, 0} {
+		if lipgloss.Width(lipgloss.JoinHorizontal(lipgloss.Left, parts...)) <= avail {
+			break
+		}
+		parts[drop] = ""
+	}
+	badges := lipgloss.JoinHorizontal(lipgloss.Left, parts...)
+
+	var (
+		titleRes string
+		descRes  string
+	)
+
+	if d.Marked[pathKey(p.Path)] {
+		icon = "✅"
+		if d.UseNerdFonts {
+			icon = "\uf14a"
+		}
+	}
+	title := fmt.Sprintf("%s %s", icon, truncate(p.Name, avail-lipgloss.Width(icon)-1))
+	displayPath := shortenPath(p.Path, min(60, avail))
+	kind := d.DriveKinds[filepath.VolumeName(p.Path)]
+	if kind == "" && inOneDrive(p.Path) {
+		kind = "cloud"
+	}
+	if label := driveKindLabel(kind); label != "" {
+		displayPath += " " + markerSyncStyle.Render(label)
+	}
+
+	if index == m.Index() {
+		titleRes = selectedItemStyle.Render(title)
+		descRes = selectedDescStyle.Render(
+			fmt.Sprintf("%s\n%s", badges, displayPath),
+		)
+	} else {
+		titleRes = itemTitleStyle.Render(title)
+		descRes = fmt.Sprintf("   %s\n   %s", badges, itemDescStyle.Render(displayPath))
+	}
+
+	fmt.Fprint(w, titleRes+"\n"+descRes)
+}
+
+// ======================================================================================
+// TEA MODEL
+// ======================================================================================
+
+type AppState int
+
+const (
+	StateConfig AppState = iota
+	StateList
+	StateLaunching
+	StateSuccess
+	StateError
+	StateUpdateFound
+	StateUpdating
+	StateIDEList
+	StateConfirm
+	StateDetails
+	StateBranches
+	StateStashPrompt
+	StateNewBranch
+	StateExport
+	StateSessions
+	StateSessionName
+	StatePreview
+	StateWorkDirs
+	StateEntryPoints
+	StateTags
+	StateRunAs
+	StateCompare
+	StateLoading // first scan at startup
+)
+
+type model struct {
+	state           AppState
+	config          Config
+	list            list.Model
+	textInput       textinput.Model
+	spinner         spinner.Model
+	logMsg          string
+	selectedPrj     ProjectInfo
+	err             error
+	width           int
+	height          int
+	updateVer       string
+	updateURL       string
+	directMode      bool              // true when launched with a CLI path argument — list is never initialized
+	ideVersions     []string          // installed IDE versions, newest first (StateIDEList)
+	idePaths        map[string]string // version -> executable
+	ideCursor       int
+	autoUpdating    bool                // silent update download in progress
+	restartPending  bool                // silent update applied, restart once launch finishes
+	restartOnExit   bool                // main runs restartApp after the program quit
+	noUpdate        bool                // --no-update passed on the command line
+	typeCounts      map[ProjectType]int // per-type totals, computed in applyScan
+	lastListPath    string              // selection remembered while away from StateList
+	lastListIndex   int
+	statusMsg       string               // transient message shown in the status line
+	launchSeq       int                  // incremented per launch, stale results are ignored
+	slowLaunch      bool                 // launch takes longer than Config.SlowLaunchHintSec
+	readOnlyLaunch  bool                 // project is backed up before opening
+	confirmText     string               // question shown in StateConfirm
+	confirmYes      func(*model) tea.Cmd // action run when the user answers "y"
+	scanGen         int                  // incremented per applyScan, stale background results are dropped
+	inline          bool                 // running without alt-screen (--inline or Config.Inline)
+	armedPath       string               // ConfirmLaunch: project waiting for the second Enter
+	armedSeq        int
+	branches        []string // local branches of branchRoot (StateBranches)
+	branchCursor    int
+	branchRoot      string
+	branchCurrent   string
+	pendingBranch   string          // checkout target waiting for the stash decision
+	branchInput     textinput.Model // new branch name (StateNewBranch)
+	detailsRemote   string          // origin URL of the project shown in StateDetails
+	remotePending   bool
+	exportCount     int             // projects offered for export (StateExport)
+	confirmTitle    string          // overrides the "CONFIRM" heading for critical warnings
+	marked          map[string]bool // pathKey -> marked with Space, for sessions
+	sessionNames    []string
+	sessionCursor   int
+	sessionInput    textinput.Model      // session name (StateSessionName)
+	errOp           string               // operation that failed, shown in StateError
+	errRetry        func(*model) tea.Cmd // repeats the failed operation ("r" in StateError)
+	errScroll       int
+	launchRetry     func(*model) tea.Cmd // repeats the current launch
+	lastAction      *repeatAction        // repeated with "."
+	running         []RunningIDE         // refreshed every RunningIDERefresh
+	preview         viewport.Model       // .pcwex file list (StatePreview)
+	dlRead          int64                // update download progress
+	dlTotal         int64                // Content-Length, 0 when unknown
+	diskFree        map[string]uint64    // volume -> free bytes, refreshed every DiskFreeRefresh
+	gitRequested    map[string]bool      // paths whose git info was requested since the last refresh
+	scanTimedOut    bool                 // last scan hit Config.ScanTimeoutSec, the list is partial
+	unavailableDirs []string             // work dirs that could not be opened at startup (StateWorkDirs)
+	listReady       bool                 // list was built once, later scans only swap its items
+	installedIDEs   map[string]string    // FindInstalledIDEs at the last scan
+	lastScanTime    time.Time            // end of the last scan
+	gitFilter       string               // "" (all), GitFilterOnly or GitFilterNone
+	hidden          []ProjectInfo        // projects left out by gitFilter, kept for re-sorting
+	entries         []ProjectInfo        // entry points found in the project folder (StateEntryPoints)
+	entryCursor     int
+	entryRoot       string            // folder the entries were scanned in
+	ideFor          ProjectInfo       // project selected when the IDE list was opened
+	driveKinds      map[string]string // volume -> driveKind, filled in applyScan
+	gitMissing      bool              // git --version failed at startup, git features are off
+	tagInput        textinput.Model   // tags of selectedPrj (StateTags)
+	launchErrors    int               // failed launches this session
+	runAsUser       textinput.Model   // account for StateRunAs
+	runAsPass       textinput.Model   // password, never stored or logged
+	showArchived    bool              // list also shows Config.Archived projects
+	detailsLibs     []string          // libraries from the metadata of selectedPrj (StateDetails)
+	iconRules       []iconRule        // compiled Config.IconRules, see setConfig
+	libsPending     bool
+	previewPending  bool           // archiveListing for StatePreview not back yet
+	compare         [2]ProjectInfo // projects shown side by side in StateCompare
+	compareFacts    [2]compareFacts
+	comparePending  bool
+	configErr       string // why the path entered in StateConfig was rejected
+}
+
+func initialModel(directProj *ProjectInfo, noUpdate bool) model {
+	ti := textinput.New()
+	ti.Placeholder = "C:\\PhoenixProjects"
+	ti.Focus()
+	ti.CharLimit = 256
+	ti.Width = 50
+	ti.PromptStyle = focusedInputStyle
+	ti.TextStyle = focusedInputStyle
+	ti.ShowSuggestions = true
+	ti.CompletionStyle = subTextStyle
+
+	sp := spinner.New()
+	sp.Spinner = spinner.Dot
+	sp.Style = lipgloss.NewStyle().Foreground(colPrimary)
+
+	m := model{
+		state:     StateConfig,
+		textInput: ti,
+		spinner:   sp,
+		noUpdate:  noUpdate,
+		marked:    make(map[string]bool),
+	}
+
+	cfg, err := loadConfig()
+	if directProj != nil {
+		if err == nil {
+			m.setConfig(cfg)
+		}
+		m.selectedPrj = *directProj
+		m.state = StateLaunching
+		m.directMode = true
+		return m
+	}
+
+	if err == nil && len(cfg.WorkDirs) > 0 {
+		m.setConfig(cfg)
+		if m.unavailableDirs = unavailableWorkDirs(cfg.WorkDirs); len(m.unavailableDirs) > 0 {
+			m.state = StateWorkDirs
+			return m
+		}
+		m.pruneHistory()
+		m.state = StateLoading // the scan runs from Init
+	}
+
+	return m
+}
+
+// openRunAs asks for the account to launch proj under (StateRunAs).
+func (m *model) openRunAs(proj ProjectInfo) tea.Cmd {
+	m.selectedPrj = proj
+	m.runAsUser = textinput.New()
+	m.runAsUser.Placeholder = `DOMAIN\user or user@domain`
+	m.runAsUser.CharLimit = 256
+	m.runAsUser.Width = 40
+	m.runAsUser.PromptStyle = focusedInputStyle
+	m.runAsUser.TextStyle = focusedInputStyle
+	m.runAsUser.Focus()
+	m.runAsPass = textinput.New()
+	m.runAsPass.Placeholder = "password"
+	m.runAsPass.EchoMode = textinput.EchoPassword
+	m.runAsPass.CharLimit = 256
+	m.runAsPass.Width = 40
+	m.state = StateRunAs
+	return textinput.Blink
+}
+
+// setConfig replaces the config and recompiles what is derived from it.
+func (m *model) setConfig(cfg Config) {
+	m.config = cfg
+	m.iconRules = compileIconRules(cfg.IconRules)
+	if m.listReady {
+		m.list.AdditionalFullHelpKeys = projectListKeys(cfg.defaultAction())
+	}
+}
+
+// unavailableWorkDirs returns the work dirs that cannot be opened, typically
+// unmounted network drives.
+func unavailableWorkDirs(dirs []string) []string {
+	var missing []string
+	for _, dir := range dirs {
+		if err := validateWorkDir(dir); err != nil {
+			WriteLog(fmt.Sprintf("Work dir unavailable: %s (%v)", dir, err))
+			missing = append(missing, dir)
+		}
+	}
+	return missing
+}
+
+// pathKey returns a comparison key for p: Windows paths are case-insensitive
+// and accept both `\` and `/` as separators.
+func pathKey(p string) string {
+	p = strings.ReplaceAll(p, `\`, string(filepath.Separator))
+	return strings.ToLower(filepath.ToSlash(filepath.Clean(p)))
+}
+
+// samePath reports whether a and b point to the same location (textually).
+func samePath(a, b string) bool {
+	return pathKey(a) == pathKey(b)
+}
+
+// normalizePath resolves p to a clean absolute path with symlinks evaluated,
+// so the same project reached through different roots compares equal.
+func normalizePath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		p = real
+	}
+	return filepath.Clean(p)
+}
+
+// dedupeProjects drops projects found more than once through nested or
+// overlapping work dirs. The first occurrence wins.
+func dedupeProjects(projects []ProjectInfo) []ProjectInfo {
+	seen := make(map[string]bool, len(projects))
+	result := projects[:0]
+	for _, p := range projects {
+		key := pathKey(normalizePath(p.Path))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, p)
+	}
+	return result
+}
+
+const (
+	SortByName     = "name"
+	SortByLaunches = "launches"
+)
+
+// sortProjects orders flat folders first, then by name. In SortByLaunches mode
+// the most launched projects go to the top.
+func sortProjects(projects []ProjectInfo, mode string, counts map[string]int) {
+	sort.SliceStable(projects, func(i, j int) bool {
+		if mode == SortByLaunches {
+			ci, cj := counts[projects[i].Path], counts[projects[j].Path]
+			if ci != cj {
+				return ci > cj
+			}
+		}
+		if projects[i].Type == TypeFlat && projects[j].Type != TypeFlat {
+			return true
+		}
+		if projects[i].Type != TypeFlat && projects[j].Type == TypeFlat {
+			return false
+		}
+		return strings.ToLower(projects[i].Name) < strings.ToLower(projects[j].Name)
+	})
+}
+
+// applySort re-sorts the current items without rescanning.
+func (m *model) applySort() tea.Cmd {
+	items := m.list.Items()
+	projects := make([]ProjectInfo, 0, len(items)+len(m.hidden))
+	for _, it := range items {
+		if p, ok := it.(ProjectInfo); ok {
+			projects = append(projects, p)
+		}
+	}
+	projects = append(projects, m.hidden...)
+	sortProjects(projects, m.config.SortMode, m.config.LaunchCounts)
+	cmd := m.list.SetItems(m.listItems(projects))
+	m.typeCounts = countByType(m.list.Items())
+	m.restoreListPosition()
+	return cmd
+}
+
+// GroupByVersion groups the list under "2024.0"-style version headers.
+const GroupByVersion = "version"
+
+// groupHeader is a non-selectable list row that starts a group.
+type groupHeader struct {
+	Title    string
+	Count    int
+	Problems bool // the problem projects section
+}
+
+// FilterValue is empty so headers disappear while a filter is applied.
+func (h groupHeader) FilterValue() string { return "" }
+
+// groupItems turns sorted projects into list items, inserting a groupHeader
+// before every group when mode is GroupByVersion. Groups are ordered newest
+// version first, Unknown last; projects keep their order inside a group.
+func groupItems(projects []ProjectInfo, mode string) []list.Item {
+	if mode != GroupByVersion {
+		items := make([]list.Item, len(projects))
+		for i, p := range projects {
+			items[i] = p
+		}
+		return items
+	}
+	const unknown = "Unknown"
+	groups := make(map[string][]ProjectInfo)
+	var keys []string
+	for _, p := range projects {
+		key := unknown
+		if !p.VersionPending && p.Version != "" && p.Version != unknown {
+			key = majorMinor(p.Version)
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], p)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i] == unknown || keys[j] == unknown {
+			return keys[j] == unknown && keys[i] != unknown
+		}
+		return compareVersions(keys[i], keys[j]) > 0
+	})
+	items := make([]list.Item, 0, len(projects)+len(keys))
+	for _, key := range keys {
+		items = append(items, groupHeader{Title: key, Count: len(groups[key])})
+		for _, p := range groups[key] {
+			items = append(items, p)
+		}
+	}
+	return items
+}
+
+// ProblemSectionTitle heads the section of projects that cannot be opened as is.
+const ProblemSectionTitle = "Problem projects"
+
+// projectProblem names what is wrong with p, or returns "" when it looks fine.
+// Projects whose version is still being read are not judged yet.
+func projectProblem(p ProjectInfo, installed map[string]string) string {
+	switch {
+	case p.VersionPending:
+		return ""
+	case p.Broken:
+		return "broken archive"
+	case p.Type == TypeUnknown:
+		return "unknown type"
+	case p.Version == "" || p.Version == "Unknown":
+		return "no version"
+	case !hasMatchingIDE(installed, p.Version):
+		return "no IDE"
+	}
+	return ""
+}
+
+type entryPointsMsg struct {
+	root    string
+	entries []ProjectInfo
+}
+
+// entryPointsCmd rescans the folder holding p for every project entry
+// (.pcwef, .pcwex, flat folders), including the ones merged in the list.
+func entryPointsCmd(p ProjectInfo, cfg Config) tea.Cmd {
+	root := filepath.Dir(p.Path)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.scanTimeout())
+		defer cancel()
+		entries, err := ScanProjects(ctx, root, ScanOptions{FollowSymlinks: cfg.FollowSymlinks, DeferGit: true})
+		if err != nil {
+			WriteLog(fmt.Sprintf("Entry point scan of %s incomplete: %v", root, err))
+		}
+		sortProjects(entries, SortByName, nil)
+		return entryPointsMsg{root: root, entries: entries}
+	}
+}
+
+// parseTags splits user input on spaces and commas into unique tags; a
+// leading "#" is dropped.
+func parseTags(input string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, f := range strings.FieldsFunc(input, func(r rune) bool { return r == ' ' || r == ',' }) {
+		tag := strings.TrimLeft(f, "#")
+		if tag != "" && !seen[strings.ToLower(tag)] {
+			seen[strings.ToLower(tag)] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// tagFilter is the list filter: "#tag" terms keep projects having a tag
+// starting with it, the rest of the input is fuzzy matched as usual.
+func tagFilter(term string, targets []string) []list.Rank {
+	var wanted, words []string
+	for _, f := range strings.Fields(term) {
+		if strings.HasPrefix(f, "#") && len(f) > 1 {
+			wanted = append(wanted, strings.ToLower(f[1:]))
+		} else {
+			words = append(words, f)
+		}
+	}
+	if len(wanted) == 0 {
+		return list.DefaultFilter(term, targets)
+	}
+	// Indexes of targets with all wanted tags, and their names for the fuzzy part.
+	var idx []int
+	var names []string
+	for i, t := range targets {
+		name, tagPart, _ := strings.Cut(t, " #")
+		tags := strings.Split(strings.ToLower(tagPart), " #")
+		all := tagPart != ""
+		for _, w := range wanted {
+			found := false
+			for _, tag := range tags {
+				if strings.HasPrefix(tag, w) {
+					found = true
+					break
+				}
+			}
+			all = all && found
+		}
+		if all {
+			idx = append(idx, i)
+			names = append(names, name)
+		}
+	}
+	if len(words) == 0 {
+		ranks := make([]list.Rank, len(idx))
+		for i, j := range idx {
+			ranks[i] = list.Rank{Index: j}
+		}
+		return ranks
+	}
+	ranks := list.DefaultFilter(strings.Join(words, " "), names)
+	for i := range ranks {
+		ranks[i].Index = idx[ranks[i].Index]
+	}
+	return ranks
+}
+
+// entryLabel shows e relative to the scanned folder.
+func entryLabel(e ProjectInfo, root string) string {
+	if rel, err := filepath.Rel(root, e.Path); err == nil {
+		return rel
+	}
+	return e.Path
+}
+
+// Values of model.gitFilter.
+const (
+	GitFilterOnly = "git"
+	GitFilterNone = "no git"
+)
+
+// inGit reports whether p is under version control. Git info is loaded
+// lazily, so pending projects are checked for a .git folder directly.
+func inGit(p ProjectInfo) bool {
+	if p.GitPending {
+		return findGitRoot(projectDir(p)) != ""
+	}
+	return p.GitBranch != ""
+}
+
+// applyListFilters moves the projects excluded by gitFilter and the archived
+// ones (unless shown) to m.hidden.
+func (m *model) applyListFilters(projects []ProjectInfo) []ProjectInfo {
+	m.hidden = nil
+	if m.gitFilter == "" && (m.showArchived || len(m.config.Archived) == 0) {
+		return projects
+	}
+	var shown []ProjectInfo
+	for _, p := range projects {
+		keep := m.showArchived || !m.config.isArchived(p.Path)
+		if m.gitFilter != "" {
+			keep = keep && inGit(p) == (m.gitFilter == GitFilterOnly)
+		}
+		if keep {
+			shown = append(shown, p)
+		} else {
+			m.hidden = append(m.hidden, p)
+		}
+	}
+	return shown
+}
+
+func (c Config) isArchived(path string) bool {
+	for _, a := range c.Archived {
+		if samePath(a, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleArchived marks or unmarks path as archived, reports the new state.
+func (c *Config) toggleArchived(path string) bool {
+	for i, a := range c.Archived {
+		if samePath(a, path) {
+			c.Archived = append(c.Archived[:i], c.Archived[i+1:]...)
+			return false
+		}
+	}
+	c.Archived = append(c.Archived, path)
+	return true
+}
+
+// listItems builds the list rows for projects: filtered by git state,
+// grouped when enabled and with problem projects moved to a section at the end.
+func (m *model) listItems(projects []ProjectInfo) []list.Item {
+	for i := range projects {
+		projects[i].Tags = m.config.Tags[projects[i].Path]
+		projects[i].Archived = m.config.isArchived(projects[i].Path)
+	}
+	projects = m.applyListFilters(projects)
+	if !m.config.ProblemSection {
+		return groupItems(projects, m.config.GroupBy)
+	}
+	var good, problems []ProjectInfo
+	for _, p := range projects {
+		if projectProblem(p, m.installedIDEs) != "" {
+			problems = append(problems, p)
+		} else {
+			good = append(good, p)
+		}
+	}
+	items := groupItems(good, m.config.GroupBy)
+	if len(problems) > 0 {
+		items = append(items, groupHeader{Title: ProblemSectionTitle, Count: len(problems), Problems: true})
+		for _, p := range problems {
+			items = append(items, p)
+		}
+	}
+	return items
+}
+
+func (m model) delegate() projectDelegate {
+	return projectDelegate{
+		UseNerdFonts:   m.config.UseNerdFonts,
+		LaunchTimes:    m.config.LaunchTimes,
+		LaunchCounts:   m.config.LaunchCounts,
+		InstalledIDE:   m.installedIDEs,
+		Marked:         m.marked,
+		ProblemSection: m.config.ProblemSection,
+		DriveKinds:     m.driveKinds,
+		IconRules:      m.iconRules,
+	}
+}
+
+// skipGroupHeader moves the selection off a group header, in the direction
+// of travel when up is set and downwards otherwise.
+func (m *model) skipGroupHeader(up bool) {
+	items := m.list.VisibleItems()
+	idx := m.list.Index()
+	if idx < 0 || idx >= len(items) {
+		return
+	}
+	if _, ok := items[idx].(groupHeader); !ok {
+		return
+	}
+	if up && idx > 0 {
+		m.list.Select(idx - 1)
+		return
+	}
+	if idx+1 < len(items) {
+		m.list.Select(idx + 1)
+	}
+}
+
+// startScan rescans the work dirs in the background behind the loading
+// splash; scanDoneMsg applies the result.
+func (m *model) startScan() tea.Cmd {
+	if len(m.config.WorkDirs) == 0 {
+		return nil
+	}
+	m.pruneHistory()
+	m.state = StateLoading
+	return tea.Batch(m.spinner.Tick, scanCmd(m.config))
+}
+
+// pruneHistory drops entries over Config.HistoryLimit. History of deleted
+// projects is found by scanWorkDirs and dropped in applyScan.
+func (m *model) pruneHistory() {
+	if trimHistory(m.config.LaunchTimes, m.config.LaunchCounts, m.config.historyLimit()) {
+		saveConfig(m.config)
+	}
+}
+
+// scanResult is everything the list needs from disk, gathered by
+// scanWorkDirs without touching the model.
+type scanResult struct {
+	projects   []ProjectInfo // deduplicated and sorted
+	missing    []string      // launch history paths that no longer exist
+	timedOut   bool
+	installed  map[string]string
+	driveKinds map[string]string
+	at         time.Time
+}
+
+type scanDoneMsg struct{ scanResult }
+
+// scanCmd scans in the background while the loading splash is shown.
+func scanCmd(cfg Config) tea.Cmd {
+	// The model keeps writing its maps, the scan reads copies.
+	cfg.LaunchTimes = maps.Clone(cfg.LaunchTimes)
+	cfg.LaunchCounts = maps.Clone(cfg.LaunchCounts)
+	return func() tea.Msg {
+		return scanDoneMsg{scanWorkDirs(cfg)}
+	}
+}
+
+func scanWorkDirs(cfg Config) scanResult {
+	var r scanResult
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.scanTimeout())
+	defer cancel()
+	for _, dir := range cfg.WorkDirs {
+		found, err := scanDir(ctx, dir, scanOptionsFromConfig(cfg))
+		r.projects = append(r.projects, found...)
+		if ctx.Err() != nil {
+			r.timedOut = true
+			break
+		}
+		if err != nil {
+			WriteLog(fmt.Sprintf("Work dir unavailable, skipped: %s (%v)", dir, err))
+		}
+	}
+	r.projects = dedupeProjects(r.projects)
+	r.at = time.Now()
+
+	sortProjects(r.projects, cfg.SortMode, cfg.LaunchCounts)
+
+	history := make(map[string]bool)
+	for path := range cfg.LaunchTimes {
+		history[path] = true
+	}
+	for path := range cfg.LaunchCounts {
+		history[path] = true
+	}
+	historyCtx, cancelHistory := context.WithTimeout(context.Background(), cfg.scanTimeout())
+	defer cancelHistory()
+	r.missing = missingPaths(historyCtx, slices.Collect(maps.Keys(history)))
+
+	r.installed = FindInstalledIDEs()
+	r.driveKinds = make(map[string]string)
+	for _, p := range r.projects {
+		vol := filepath.VolumeName(p.Path)
+		if _, ok := r.driveKinds[vol]; !ok {
+			r.driveKinds[vol] = driveKind(vol + string(filepath.Separator))
+		}
+	}
+	return r
+}
+
+// applyScan rebuilds the list from r.
+func (m *model) applyScan(r scanResult) {
+	projects := r.projects
+	m.scanTimedOut = r.timedOut
+	m.lastScanTime = r.at
+	m.installedIDEs = r.installed
+	m.driveKinds = r.driveKinds
+	for _, path := range r.missing {
+		delete(m.config.LaunchTimes, path)
+		delete(m.config.LaunchCounts, path)
+	}
+	if pruneProjectIDEVersions(m.config.ProjectIDEVersion, m.installedIDEs) || len(r.missing) > 0 {
+		saveConfig(m.config)
+	}
+	items := m.listItems(projects)
+	m.typeCounts = countByType(items)
+	m.scanGen++
+	m.gitRequested = make(map[string]bool)
+
+	if m.config.LaunchTimes == nil {
+		m.config.LaunchTimes = make(map[string]time.Time)
+	}
+	if m.config.LaunchCounts == nil {
+		m.config.LaunchCounts = make(map[string]int)
+	}
+	delegate := m.delegate()
+	if m.listReady {
+		// Reuse the model: rebuilding it for thousands of items is slow and
+		// drops the pagination. A rescan still clears the filter.
+		m.list.ResetFilter()
+		m.list.SetDelegate(delegate)
+		m.list.SetItems(items)
+		m.list.AdditionalFullHelpKeys = projectListKeys(m.config.defaultAction())
+	} else {
+		m.list = newProjectList(items, delegate, m.config.defaultAction())
+		m.listReady = true
+	}
+	m.state = StateList
+	if m.width > 0 {
+		m.list.SetSize(m.width, m.height-2)
+	}
+	m.restoreListPosition()
+}
+
+// newProjectList builds the list model with the project key bindings.
+func newProjectList(items []list.Item, delegate projectDelegate, enterAction string) list.Model {
+	l := list.New(items, delegate, 0, 0)
+	l.Filter = tagFilter
+	l.Title = "PLCnext Projects"
+	l.SetShowHelp(false)
+	l.Styles.Title = titleStyle
+	l.Styles.PaginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
+	l.AdditionalFullHelpKeys = projectListKeys(enterAction)
+	return l
+}
+
+// projectListKeys lists the project key bindings for the full help, Enter
+// described as enterAction (Config.DefaultAction).
+func projectListKeys(enterAction string) func() []key.Binding {
+	return func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "change path")),
+			key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort by name/launches")),
+			key.NewBinding(key.WithKeys("V"), key.WithHelp("V", "group by version")),
+			key.NewBinding(key.WithKeys("P"), key.WithHelp("P", "problem projects section")),
+			key.NewBinding(key.WithKeys("F"), key.WithHelp("F", "filter: all/git/no git")),
+			key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "choose entry point")),
+			key.NewBinding(key.WithKeys("#"), key.WithHelp("#", "edit tags (filter with #tag)")),
+			key.NewBinding(key.WithKeys("L"), key.WithHelp("L", "open log")),
+			key.NewBinding(key.WithKeys("A"), key.WithHelp("A", "launch as another user")),
+			key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "archive/unarchive project")),
+			key.NewBinding(key.WithKeys("H"), key.WithHelp("H", "show/hide archived projects")),
+			key.NewBinding(key.WithKeys("X"), key.WithHelp("X", "clean bin/obj (flat projects)")),
+			key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "installed IDEs")),
+			key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "project details")),
+			key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "preview .pcwex contents")),
+			key.NewBinding(key.WithKeys("B"), key.WithHelp("B", "switch git branch")),
+			key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "open repository in browser")),
+			key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy launch command")),
+			key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "desktop shortcut")),
+			key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "export list (CSV/JSON)")),
+			key.NewBinding(key.WithKeys("E"), key.WithHelp("E", "open folder in editor")),
+			key.NewBinding(key.WithKeys("O"), key.WithHelp("O", "open folder in Explorer")),
+			key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "mark for session")),
+			key.NewBinding(key.WithKeys("W"), key.WithHelp("W", "save marked as session")),
+			key.NewBinding(key.WithKeys("C"), key.WithHelp("C", "compare two marked projects")),
+			key.NewBinding(key.WithKeys("w"), key.WithHelp("w", "sessions")),
+			key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", enterAction)),
+			key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "launch")),
+			key.NewBinding(key.WithKeys("."), key.WithHelp(".", "repeat last action")),
+			key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "launch read-only (backup first)")),
+		}
+	}
+}
+
+// defaultAction runs Config.DefaultAction on p, for Enter and a click on the
+// selected project.
+func (m *model) defaultAction(p ProjectInfo) tea.Cmd {
+	switch m.config.defaultAction() {
+	case DefaultActionDetails:
+		return m.openDetails(p)
+	case DefaultActionFolder:
+		return openFolderCmd(p)
+	}
+	if m.needsArming(p) {
+		return m.armLaunch(p, "Enter (or click)")
+	}
+	return m.requestLaunch(p, false)
+}
+
+// openDetails shows the details of p and fetches its remote URL.
+func (m *model) openDetails(p ProjectInfo) tea.Cmd {
+	m.selectedPrj = p
+	m.state = StateDetails
+	m.detailsRemote = ""
+	m.detailsLibs = nil
+	m.libsPending = true
+	cmds := []tea.Cmd{librariesCmd(p)}
+	if p.GitBranch != "" {
+		m.remotePending = true
+		cmds = append(cmds, remoteURLCmd(p))
+	}
+	return tea.Batch(cmds...)
+}
+
+// singleVisibleProject returns the project when the filtered list shows
+// exactly one (group headers do not count).
+func (m model) singleVisibleProject() (ProjectInfo, bool) {
+	var found ProjectInfo
+	n := 0
+	for _, it := range m.list.VisibleItems() {
+		if p, ok := it.(ProjectInfo); ok {
+			found = p
+			n++
+		}
+	}
+	return found, n == 1
+}
+
+// rememberListPosition stores the cursor so it survives state changes and reloads.
+func (m *model) rememberListPosition() {
+	if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+		m.lastListPath = p.Path
+		m.lastListIndex = m.list.Index()
+	}
+}
+
+// restoreListPosition selects the remembered project again. If it has
+// disappeared after a reload, the previous index is kept (clamped).
+func (m *model) restoreListPosition() {
+	items := m.list.VisibleItems()
+	if len(items) == 0 || m.lastListPath == "" {
+		return
+	}
+	for i, it := range items {
+		if p, ok := it.(ProjectInfo); ok && samePath(p.Path, m.lastListPath) {
+			m.list.Select(i)
+			return
+		}
+	}
+	idx := m.lastListIndex
+	if idx >= len(items) {
+		idx = len(items) - 1
+	}
+	m.list.Select(idx)
+	m.skipGroupHeader(false)
+}
+
+func (m *model) startLaunch(proj ProjectInfo, readOnly bool) tea.Cmd {
+	m.selectedPrj = proj
+	m.state = StateLaunching
+	m.launchSeq++
+	m.slowLaunch = false
+	m.readOnlyLaunch = readOnly
+	m.launchRetry = func(m *model) tea.Cmd { return m.startLaunch(proj, readOnly) }
+	return tea.Batch(m.spinner.Tick, launchWithSeq(proj, m.config, m.launchSeq, readOnly, false),
+		slowLaunchTimer(m.config.slowLaunchHint(), m.launchSeq))
+}
+
+// recordLaunch stores launch statistics for a successfully started project.
+func (m *model) recordLaunch(proj ProjectInfo) {
+	if proj.Path == "" {
+		return // IDE started without a project
+	}
+	if m.config.historyLimit() == 0 {
+		if trimHistory(m.config.LaunchTimes, m.config.LaunchCounts, 0) {
+			saveConfig(m.config)
+		}
+		return
+	}
+	if m.config.LaunchTimes == nil {
+		m.config.LaunchTimes = make(map[string]time.Time)
+	}
+	m.config.LaunchTimes[proj.Path] = time.Now()
+	if m.config.LaunchCounts == nil {
+		m.config.LaunchCounts = make(map[string]int)
+	}
+	m.config.LaunchCounts[proj.Path]++
+	trimHistory(m.config.LaunchTimes, m.config.LaunchCounts, m.config.historyLimit())
+	if err := saveConfig(m.config); err != nil {
+		WriteLog(fmt.Sprintf("Failed to save launch statistics: %v", err))
+	}
+}
+
+// trimHistory keeps the launch statistics of the limit most recently launched
+// projects. The maps are edited in place, the list delegate shares them.
+// Reports whether anything was removed.
+func trimHistory(times map[string]time.Time, counts map[string]int, limit int) bool {
+	paths := make([]string, 0, len(counts))
+	seen := make(map[string]bool, len(counts))
+	for p := range times {
+		paths = append(paths, p)
+		seen[p] = true
+	}
+	for p := range counts {
+		if !seen[p] {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) <= limit {
+		return false
+	}
+	// Newest first, entries without a launch time count as oldest.
+	sort.Slice(paths, func(i, j int) bool { return times[paths[i]].After(times[paths[j]]) })
+	for _, p := range paths[limit:] {
+		delete(times, p)
+		delete(counts, p)
+	}
+	return true
+}
+
+// confirm asks a yes/no question; onYes runs from StateList on "y".
+func (m *model) confirm(text string, onYes func(*model) tea.Cmd) {
+	m.confirmText = text
+	m.confirmTitle = ""
+	m.confirmYes = onYes
+	m.state = StateConfirm
+}
+
+func (m *model) openSessions() {
+	m.sessionNames = m.sessionNames[:0]
+	for name := range m.config.Sessions {
+		m.sessionNames = append(m.sessionNames, name)
+	}
+	sort.Strings(m.sessionNames)
+	if m.sessionCursor >= len(m.sessionNames) {
+		m.sessionCursor = max(0, len(m.sessionNames)-1)
+	}
+	m.state = StateSessions
+}
+
+// sessionTarget stands for the named session in needsArming and armLaunch.
+func sessionTarget(name string) ProjectInfo {
+	return ProjectInfo{Name: "session " + name, Path: "session:" + name}
+}
+
+// openSession launches every project of the named session one after another.
+func (m *model) openSession(name string) tea.Cmd {
+	var projects []ProjectInfo
+	for _, path := range m.config.Sessions[name] {
+		found := false
+		for _, it := range m.list.Items() {
+			if p, ok := it.(ProjectInfo); ok && samePath(p.Path, path) {
+				projects = append(projects, p)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		p, err := buildProjectInfoFromPath(path)
+		if err != nil {
+			WriteLog(fmt.Sprintf("Session %q: skipping %s: %v", name, path, err))
+			continue
+		}
+		projects = append(projects, p)
+	}
+	WriteLog(fmt.Sprintf("Opening session %q (%d projects)", name, len(projects)))
+	m.armedPath = ""
+	m.rememberLaunch("open session "+name, sessionTarget(name), func(m *model) tea.Cmd { return m.openSession(name) })
+	var todo []ProjectInfo
+	skipped := 0
+	for _, p := range projects {
+		if why, busy := projectInUse(p, m.running, m.config.LockFilePatterns); busy {
+			WriteLog(fmt.Sprintf("Session %q: %s is already open (%s), skipped", name, p.Name, why))
+			skipped++
+			continue
+		}
+		todo = append(todo, p)
+	}
+	return m.sessionChecks(name, todo, nil, skipped)
+}
+
+// sessionChecks runs the pre-launch checks of the session projects one after
+// another and then launches the confirmed ones. Declining a question cancels
+// the whole session.
+func (m *model) sessionChecks(name string, todo, confirmed []ProjectInfo, skipped int) tea.Cmd {
+	if len(todo) == 0 {
+		m.selectedPrj = ProjectInfo{Name: "Session " + name}
+		m.state = StateLaunching
+		m.launchSeq++
+		m.slowLaunch = false
+		m.readOnlyLaunch = false
+		m.launchRetry = func(m *model) tea.Cmd { return m.openSession(name) }
+		return tea.Batch(m.spinner.Tick, launchSessionCmd(confirmed, m.config, m.launchSeq, skipped))
+	}
+	return m.preLaunchChecks(todo[0], func(m *model, p ProjectInfo) tea.Cmd {
+		return m.sessionChecks(name, todo[1:], append(slices.Clip(confirmed), p), skipped)
+	})
+}
+
+// repeatAction is the last user action, repeated with ".". run goes through
+// the same confirmations as the original action.
+type repeatAction struct {
+	desc   string
+	run    func(*model) tea.Cmd
+	launch ProjectInfo // what run launches, armed first (Config.ConfirmLaunch)
+}
+
+func (m *model) remember(desc string, run func(*model) tea.Cmd) {
+	m.lastAction = &repeatAction{desc: desc, run: run}
+}
+
+// rememberLaunch is remember for an action launching target.
+func (m *model) rememberLaunch(desc string, target ProjectInfo, run func(*model) tea.Cmd) {
+	m.lastAction = &repeatAction{desc: desc, run: run, launch: target}
+}
+
+// checkoutBranch switches root to target, asking about uncommitted changes first.
+func (m *model) checkoutBranch(root, current, target string) tea.Cmd {
+	m.branchRoot, m.branchCurrent = root, current
+	m.remember("checkout "+target, func(m *model) tea.Cmd {
+		branch, _ := gitInfoAt(root)
+		if branch == target {
+			return m.setStatus("Already on " + target)
+		}
+		return m.checkoutBranch(root, branch, target)
+	})
+	if _, st := gitInfoAt(root); st.Dirty {
+		m.pendingBranch = target
+		m.state = StateStashPrompt
+		return nil
+	}
+	m.returnToList()
+	return switchBranchCmd(root, target, StashNone)
+}
+
+// fail shows err in StateError; retry (optional) repeats the failed operation.
+func (m *model) fail(op string, err error, retry func(*model) tea.Cmd) {
+	m.err = err
+	m.errOp = op
+	m.errRetry = retry
+	m.errScroll = 0
+	m.state = StateError
+}
+
+// errorLines wraps the current error for the scrollable StateError view.
+func (m model) errorLines() []string {
+	if m.err == nil {
+		return nil
+	}
+	return strings.Split(lipgloss.NewStyle().Width(60).Render(m.err.Error()), "\n")
+}
+
+// requestLaunch starts proj after the pre-launch checks.
+func (m *model) requestLaunch(proj ProjectInfo, readOnly bool) tea.Cmd {
+	m.armedPath = ""
+	desc := "launch " + proj.Name
+	if readOnly {
+		desc += " (read-only)"
+	}
+	m.rememberLaunch(desc, proj, func(m *model) tea.Cmd { return m.requestLaunch(proj, readOnly) })
+	return m.preLaunchChecks(proj, func(m *model, proj ProjectInfo) tea.Cmd {
+		return m.startLaunch(proj, readOnly)
+	})
+}
+
+// launchStep continues a launch after a pre-launch check passed or the user
+// confirmed it. proj carries what the check filled in (e.g. the version).
+type launchStep func(m *model, proj ProjectInfo) tea.Cmd
+
+// preLaunchChecks asks about a project on a network drive, uninitialized
+// submodules, a project newer than every installed IDE, an IDE busy with
+// another project and a project that looks opened already, then runs next.
+func (m *model) preLaunchChecks(proj ProjectInfo, next launchStep) tea.Cmd {
+	return m.checkDrive(proj, func(m *model, proj ProjectInfo) tea.Cmd {
+		return m.checkSubmodules(proj, func(m *model, proj ProjectInfo) tea.Cmd {
+			return m.checkNewerProject(proj, func(m *model, proj ProjectInfo) tea.Cmd {
+				return m.checkRunningIDE(proj, func(m *model, proj ProjectInfo) tea.Cmd {
+					return m.checkProjectLock(proj, next)
+				})
+			})
+		})
+	})
+}
+
+// checkDrive warns about a project outside a local disk (Config.WarnNetworkLaunch).
+func (m *model) checkDrive(proj ProjectInfo, next launchStep) tea.Cmd {
+	if kind := driveKind(proj.Path); kind != "" && m.config.WarnNetworkLaunch {
+		m.confirm(fmt.Sprintf("%s is on a %s drive.\n\nOpening it may be slow and lock files may not work reliably.\n\nLaunch anyway?", proj.Name, kind),
+			func(m *model) tea.Cmd { return next(m, proj) })
+		return nil
+	}
+	return next(m, proj)
+}
+
+// checkSubmodules offers to initialize missing git submodules before the
+// launch; submodulesInitMsg continues with next.
+func (m *model) checkSubmodules(proj ProjectInfo, next launchStep) tea.Cmd {
+	if proj.Git.Submodules > 0 {
+		m.confirm(fmt.Sprintf("%d git submodule(s) are not initialized, the project may be incomplete.\n\nRun git submodule update --init and launch?", proj.Git.Submodules),
+			func(m *model) tea.Cmd {
+				return tea.Batch(m.setStatus("Initializing submodules…"), initSubmodulesCmd(proj, next))
+			})
+		return nil
+	}
+	return next(m, proj)
+}
+
+// checkNewerProject blocks opening a project saved by a newer IDE than any
+// installed one: opening it in an older IDE cannot be undone. It compares
+// with the IDEs found by the last scan; a version not read yet is read by
+// projectVersionCmd first, the archive may be on a slow share.
+func (m *model) checkNewerProject(proj ProjectInfo, next launchStep) tea.Cmd {
+	if proj.VersionPending {
+		return tea.Batch(m.setStatus("Reading version of "+proj.Name+"…"), projectVersionCmd(proj, next))
+	}
+	newest := ""
+	if versions := sortedIDEVersions(m.installedIDEs); len(versions) > 0 {
+		newest = versions[0]
+	}
+	if newest != "" && proj.Version != "Unknown" && compareVersions(proj.Version, newest) > 0 {
+		WriteLog(fmt.Sprintf("WARNING: project %s is v%s, newest installed IDE is v%s", proj.Path, proj.Version, newest))
+		m.confirm(fmt.Sprintf("Project version v%s is NEWER than the newest installed IDE v%s.\n\n"+
+			"The older IDE may fail to open it or damage it.\n\nLaunch anyway?", proj.Version, newest),
+			func(m *model) tea.Cmd {
+				WriteLog("User confirmed launch of newer project: " + proj.Path)
+				return next(m, proj)
+			})
+		m.confirmTitle = "⛔ PROJECT NEWER THAN INSTALLED IDE"
+		return nil
+	}
+	return next(m, proj)
+}
+
+// projectVersionMsg is proj with its archive version read, checkNewerProject
+// continues with it.
+type projectVersionMsg struct {
+	proj ProjectInfo
+	next launchStep
+}
+
+func projectVersionCmd(proj ProjectInfo, next launchStep) tea.Cmd {
+	return func() tea.Msg {
+		proj.Version = archiveVersion(proj.Path)
+		proj.VersionPending = false
+		return projectVersionMsg{proj: proj, next: next}
+	}
+}
+
+// checkRunningIDE warns when the IDE version proj would open in already has
+// another project open, since the launch hands proj to that instance.
+// It runs inside Update, so it only uses cached data: m.running (refreshed
+// every RunningIDERefresh) and the IDEs found by the last scan.
+func (m *model) checkRunningIDE(proj ProjectInfo, next launchStep) tea.Cmd {
+	if len(m.running) == 0 || proj.VersionPending {
+		return next(m, proj)
+	}
+	idePath, _, err := selectProjectIDE(proj, m.config, m.installedIDEs)
+	if err != nil {
+		return next(m, proj) // launchProjectCmd reports it
+	}
+	ver := ideVersionFromPath(idePath)
+	for _, r := range m.running {
+		if r.Version != ver || r.Project == "" || samePath(r.Project, proj.Path) {
+			continue
+		}
+		WriteLog(fmt.Sprintf("IDE v%s (PID %d) already has %s open", ver, r.PID, r.Project))
+		m.confirm(fmt.Sprintf("PLCnext Engineer v%s already has another project open:\n%s\n\nOpen %s in it anyway?", ver, r.Project, proj.Name),
+			func(m *model) tea.Cmd { return next(m, proj) })
+		return nil
+	}
+	return next(m, proj)
+}
+
+// checkProjectLock asks before opening a project whose lock file shows it is
+// open already.
+func (m *model) checkProjectLock(proj ProjectInfo, next launchStep) tea.Cmd {
+	if lock, found := findProjectLock(proj, m.config.LockFilePatterns); found {
+		WriteLog("Project lock file detected: " + lock)
+		m.confirm(fmt.Sprintf("Project seems to be open already (possibly in another IDE version):\n%s\n\nLaunch anyway?", lock),
+			func(m *model) tea.Cmd { return next(m, proj) })
+		return nil
+	}
+	return next(m, proj)
+}
+
+// listTop returns the terminal row of the first list item. Mouse rows are
+// screen based: inline the view is drawn at the bottom of the terminal
+// instead of from the top, and the header is measured as rendered.
+func (m model) listTop() int {
+	top := docStyle.GetMarginTop()
+	if m.inline {
+		top += max(0, m.height-lipgloss.Height(m.View()))
+	}
+	if m.list.ShowTitle() || m.list.FilteringEnabled() {
+		top += lipgloss.Height(m.list.Styles.TitleBar.Render(m.list.Styles.Title.Render(m.list.Title)))
+	}
+	if m.list.ShowStatusBar() {
+		top += lipgloss.Height(m.list.Styles.StatusBar.Render(" "))
+	}
+	return top
+}
+
+// listItemAt maps a terminal row to the index of the list item drawn there.
+func (m model) listItemAt(y int) (int, bool) {
+	y -= m.listTop()
+	if y < 0 {
+		return 0, false
+	}
+	delegate := projectDelegate{}
+	slot := delegate.Height() + delegate.Spacing()
+	if y%slot >= delegate.Height() {
+		return 0, false // spacing between items
+	}
+	idx := m.list.Paginator.Page*m.list.Paginator.PerPage + y/slot
+	if y/slot >= m.list.Paginator.PerPage || idx >= len(m.list.VisibleItems()) {
+		return 0, false
+	}
+	return idx, true
+}
+
+func (m *model) returnToList() {
+	m.state = StateList
+	m.restoreListPosition()
+}
+
+func (m *model) openIDEList() {
+	m.idePaths = FindInstalledIDEs()
+	m.ideVersions = sortedIDEVersions(m.idePaths)
+	m.ideFor, _ = m.list.SelectedItem().(ProjectInfo)
+	m.ideCursor = 0
+	for i, v := range m.ideVersions {
+		if v == m.config.DefaultIDEVersion {
+			m.ideCursor = i
+		}
+	}
+	for i, v := range m.ideVersions {
+		if v == m.config.ProjectIDEVersion[m.ideFor.Path] {
+			m.ideCursor = i
+		}
+	}
+	m.state = StateIDEList
+}
+
+// pruneProjectIDEVersions drops per-project IDE choices whose version is no
+// longer installed. Reports whether anything was removed.
+func pruneProjectIDEVersions(choices map[string]string, installed map[string]string) bool {
+	if len(installed) == 0 {
+		return false // IDE folder unreadable, do not lose every choice
+	}
+	removed := false
+	for path, ver := range choices {
+		if _, ok := installed[ver]; !ok {
+			WriteLog(fmt.Sprintf("IDE version %s chosen for %s is no longer installed, choice removed", ver, path))
+			delete(choices, path)
+			removed = true
+		}
+	}
+	return removed
+}
+
+func countByType(items []list.Item) map[ProjectType]int {
+	counts := make(map[ProjectType]int)
+	for _, it := range items {
+		if p, ok := it.(ProjectInfo); ok {
+			counts[p.Type]++
+		}
+	}
+	return counts
+}
+
+// typeSummary renders "DIR: 5 | PCWEX: 3 | PCWEF: 2", or "shown/total" per
+// type while a filter is applied.
+func (m model) typeSummary() string {
+	var visible map[ProjectType]int
+	if m.list.FilterState() != list.Unfiltered {
+		visible = countByType(m.list.VisibleItems())
+	}
+	var parts []string
+	for _, t := range []ProjectType{TypeFlat, TypePCWEX, TypePCWEF} {
+		total := m.typeCounts[t]
+		if total == 0 {
+			continue
+		}
+		if visible != nil {
+			parts = append(parts, fmt.Sprintf("%s: %d/%d", t.Label(), visible[t], total))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %d", t.Label(), total))
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// MaxParallelArchiveReads limits concurrent .pcwex unzips in the background.
+const MaxParallelArchiveReads = 4
+
+var archiveSem = make(chan struct{}, MaxParallelArchiveReads)
+
+type archiveVersionMsg struct {
+	path        string
+	version     string
+	flatVersion string
+	broken      bool
+	gen         int
+}
+
+func archiveVersionCmd(path string, gen int) tea.Cmd {
+	return func() tea.Msg {
+		archiveSem <- struct{}{}
+		defer func() { <-archiveSem }()
+		ver, broken := archiveVersionStatus(path)
+		return archiveVersionMsg{path: path, version: ver, flatVersion: flatVersionMismatch(path, ver), broken: broken, gen: gen}
+	}
+}
+
+// MaxParallelGitCalls limits concurrent git processes in the background.
+const MaxParallelGitCalls = 4
+
+var gitSem = make(chan struct{}, MaxParallelGitCalls)
+
+type gitInfoMsg struct {
+	path   string
+	branch string
+	status GitStatus
+	gen    int
+}
+
+func gitInfoCmd(p ProjectInfo, gen int) tea.Cmd {
+	return func() tea.Msg {
+		gitSem <- struct{}{}
+		defer func() { <-gitSem }()
+		branch, status := cachedGitInfo(projectDir(p))
+		return gitInfoMsg{path: p.Path, branch: branch, status: status, gen: gen}
+	}
+}
+
+type gitRefreshMsg struct{ gen int }
+
+// gitRefreshTimer re-reads git info once the cache entries have expired.
+func gitRefreshTimer(gen int) tea.Cmd {
+	return tea.Tick(GitCacheTTL, func(time.Time) tea.Msg { return gitRefreshMsg{gen: gen} })
+}
+
+// backgroundScanCmds schedules the deferred work for the current list.
+func (m model) backgroundScanCmds() tea.Cmd {
+	cmds := []tea.Cmd{gitRefreshTimer(m.scanGen)}
+	for _, it := range m.list.Items() {
+		p, ok := it.(ProjectInfo)
+		if !ok {
+			continue
+		}
+		if p.VersionPending {
+			cmds = append(cmds, archiveVersionCmd(p.Path, m.scanGen))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// visibleGitCmds requests git info for the projects on the current list page
+// that have not been requested since the last refresh.
+func (m model) visibleGitCmds() tea.Cmd {
+	if m.gitRequested == nil {
+		return nil
+	}
+	items := m.list.VisibleItems()
+	start, end := m.list.Paginator.GetSliceBounds(len(items))
+	var cmds []tea.Cmd
+	for _, it := range items[start:end] {
+		p, ok := it.(ProjectInfo)
+		if !ok || m.gitRequested[p.Path] {
+			continue
+		}
+		m.gitRequested[p.Path] = true
+		cmds = append(cmds, gitInfoCmd(p, m.scanGen))
+	}
+	return tea.Batch(cmds...)
+}
+
+// updateItem replaces the list item with the same path.
+func (m *model) updateItem(path string, update func(p *ProjectInfo)) tea.Cmd {
+	for i, it := range m.list.Items() {
+		if p, ok := it.(ProjectInfo); ok && p.Path == path {
+			update(&p)
+			if m.selectedPrj.Path == path {
+				m.selectedPrj = p
+			}
+			return m.list.SetItem(i, p)
+		}
+	}
+	for i := range m.hidden {
+		if m.hidden[i].Path == path {
+			update(&m.hidden[i])
+		}
+	}
+	return nil
+}
+
+// LaunchConfirmWindow is how long the first Enter stays armed (Config.ConfirmLaunch).
+const LaunchConfirmWindow = 2 * time.Second
+
+type disarmLaunchMsg struct{ seq int }
+
+// needsArming reports whether a launch of proj started by the user must only
+// arm it first (Config.ConfirmLaunch). Every launch started by a key or a
+// click checks it, sessions and "." included.
+func (m model) needsArming(proj ProjectInfo) bool {
+	return m.config.ConfirmLaunch && !samePath(m.armedPath, proj.Path)
+}
+
+// armLaunch remembers proj after the first press of key; a second press
+// within LaunchConfirmWindow launches it.
+func (m *model) armLaunch(proj ProjectInfo, key string) tea.Cmd {
+	m.armedPath = proj.Path
+	m.armedSeq++
+	seq := m.armedSeq
+	return tea.Batch(
+		m.setStatus("Press "+key+" again to launch "+proj.Name),
+		tea.Tick(LaunchConfirmWindow, func(time.Time) tea.Msg { return disarmLaunchMsg{seq: seq} }),
+	)
+}
+
+type branchSwitchMsg struct {
+	root    string
+	branch  string
+	mode    StashMode
+	message string
+	err     error
+}
+
+func switchBranchCmd(root, branch string, mode StashMode) tea.Cmd {
+	return func() tea.Msg {
+		WriteLog(fmt.Sprintf("Switching %s to branch %s (stash mode %d)", root, branch, mode))
+		msg, err := switchBranch(root, branch, mode)
+		if err != nil {
+			WriteLog(fmt.Sprintf("Branch switch error: %v", err))
+		} else {
+			WriteLog(msg)
+		}
+		invalidateGitCache(root)
+		return branchSwitchMsg{root: root, branch: branch, mode: mode, message: msg, err: err}
+	}
+}
+
+type remoteURLMsg struct {
+	path string
+	url  string
+	err  error
+}
+
+type librariesMsg struct {
+	path string
+	libs []string
+}
+
+func librariesCmd(p ProjectInfo) tea.Cmd {
+	return func() tea.Msg {
+		libs, err := projectLibraries(p)
+		if err != nil {
+			WriteLog(fmt.Sprintf("Libraries of %s: %v", p.Path, err))
+		}
+		return librariesMsg{path: p.Path, libs: libs}
+	}
+}
+
+// compareFacts are the values of the compare view that need disk access.
+type compareFacts struct {
+	Size     uint64
+	Modified time.Time // newest file of the project
+	Firmware string    // firmware properties from the metadata
+}
+
+type compareMsg struct {
+	paths [2]string
+	facts [2]compareFacts
+}
+
+func compareCmd(a, b ProjectInfo) tea.Cmd {
+	return func() tea.Msg {
+		var msg compareMsg
+		for i, p := range [2]ProjectInfo{a, b} {
+			msg.paths[i] = p.Path
+			for _, f := range projectFiles(p) {
+				filepath.WalkDir(f, func(_ string, d fs.DirEntry, err error) error {
+					if err != nil || d.IsDir() {
+						return nil
+					}
+					if info, err := d.Info(); err == nil {
+						msg.facts[i].Size += uint64(info.Size())
+						if info.ModTime().After(msg.facts[i].Modified) {
+							msg.facts[i].Modified = info.ModTime()
+						}
+					}
+					return nil
+				})
+			}
+			libs, _ := projectLibraries(p)
+			var firmware []string
+			for _, lib := range libs {
+				if strings.Contains(strings.ToLower(lib), "firmware") {
+					firmware = append(firmware, lib)
+				}
+			}
+			msg.facts[i].Firmware = strings.Join(firmware, ", ")
+		}
+		return msg
+	}
+}
+
+func remoteURLCmd(p ProjectInfo) tea.Cmd {
+	return func() tea.Msg {
+		url, err := remoteURL(projectDir(p))
+		if err != nil && !errors.Is(err, errNoRemote) {
+			WriteLog(fmt.Sprintf("Remote URL of %s: %v", p.Path, err))
+		}
+		return remoteURLMsg{path: p.Path, url: url, err: err}
+	}
+}
+
+// openFolderCmd opens the folder holding the project in Explorer.
+func openFolderCmd(p ProjectInfo) tea.Cmd {
+	return func() tea.Msg {
+		dir := projectDir(p)
+		if err := openURL(dir); err != nil {
+			WriteLog(fmt.Sprintf("Open folder %s failed: %v", dir, err))
+			return statusMsg{text: "Failed to open folder, see log"}
+		}
+		WriteLog("Opened folder: " + dir)
+		return statusMsg{text: "Opened " + shortenPath(dir, 50)}
+	}
+}
+
+// openRepoCmd opens the origin of the project's repository in the default browser.
+func openRepoCmd(p ProjectInfo) tea.Cmd {
+	return func() tea.Msg {
+		remote, err := remoteURL(projectDir(p))
+		if err != nil {
+			if errors.Is(err, errNoRemote) {
+				return statusMsg{text: "Repository has no origin remote"}
+			}
+			return statusMsg{text: err.Error()}
+		}
+		url, err := remoteWebURL(remote)
+		if err != nil {
+			WriteLog(err.Error())
+			return statusMsg{text: "Cannot open " + remote + " in a browser"}
+		}
+		if err := openURL(url); err != nil {
+			WriteLog(fmt.Sprintf("Open %s failed: %v", url, err))
+			return statusMsg{text: "Failed to open browser"}
+		}
+		WriteLog("Opened repository: " + url)
+		return statusMsg{text: "Opened " + url}
+	}
+}
+
+type submodulesInitMsg struct {
+	proj ProjectInfo
+	next launchStep // rest of the launch, see checkSubmodules
+	root string
+	err  error
+}
+
+type cleanScanMsg struct {
+	proj ProjectInfo
+	dirs []string
+	size uint64
+}
+
+func cleanScanCmd(proj ProjectInfo, folder string, names []string) tea.Cmd {
+	return func() tea.Msg {
+		dirs, size := cleanTargets(folder, names)
+		return cleanScanMsg{proj: proj, dirs: dirs, size: size}
+	}
+}
+
+type cleanDoneMsg struct {
+	proj  ProjectInfo
+	freed uint64
+	err   error
+}
+
+// cleanCmd removes dirs, stopping at the first failure.
+func cleanCmd(proj ProjectInfo, dirs []string) tea.Cmd {
+	return func() tea.Msg {
+		var freed uint64
+		for _, dir := range dirs {
+			size := pathSize(dir)
+			if err := os.RemoveAll(dir); err != nil {
+				WriteLog(fmt.Sprintf("Clean of %s failed: %v", dir, err))
+				return cleanDoneMsg{proj: proj, freed: freed, err: err}
+			}
+			freed += size
+			WriteLog(fmt.Sprintf("Removed %s (%s)", dir, humanizeBytes(size)))
+		}
+		WriteLog(fmt.Sprintf("Cleaned %s: %s freed", proj.Path, humanizeBytes(freed)))
+		return cleanDoneMsg{proj: proj, freed: freed}
+	}
+}
+
+// projectInUse reports why proj must not be modified: an IDE in running has
+// it open or a lock file is present.
+func projectInUse(proj ProjectInfo, running []RunningIDE, lockPatterns []string) (string, bool) {
+	for _, r := range running {
+		if r.Project != "" && samePath(r.Project, proj.Path) {
+			return fmt.Sprintf("open in PLCnext Engineer v%s (PID %d)", r.Version, r.PID), true
+		}
+	}
+	if lock, found := findProjectLock(proj, lockPatterns); found {
+		return "lock file " + filepath.Base(lock), true
+	}
+	return "", false
+}
+
+func initSubmodulesCmd(proj ProjectInfo, next launchStep) tea.Cmd {
+	return func() tea.Msg {
+		root := findGitRoot(projectDir(proj))
+		_, err := runGitTimeout(root, SubmoduleInitTimeout, "submodule", "update", "--init")
+		if err != nil {
+			WriteLog(fmt.Sprintf("Submodule init in %s failed: %v", root, err))
+		} else {
+			WriteLog("Initialized submodules in " + root)
+		}
+		invalidateGitCache(root)
+		return submodulesInitMsg{proj: proj, next: next, root: root, err: err}
+	}
+}
+
+type branchCreatedMsg struct {
+	root string
+	name string
+	err  error
+}
+
+func createBranchCmd(root, name string) tea.Cmd {
+	return func() tea.Msg {
+		err := createBranch(root, name)
+		if err != nil {
+			WriteLog(fmt.Sprintf("Create branch %s in %s failed: %v", name, root, err))
+		} else {
+			WriteLog(fmt.Sprintf("Created branch %s in %s", name, root))
+		}
+		invalidateGitCache(root)
+		return branchCreatedMsg{root: root, name: name, err: err}
+	}
+}
+
+// openBranches shows the branch picker for proj.
+func (m *model) openBranches(proj ProjectInfo) tea.Cmd {
+	root := findGitRoot(projectDir(proj))
+	if root == "" {
+		return m.setStatus("Not a git repository")
+	}
+	branches, err := listBranches(root)
+	if err != nil {
+		WriteLog(fmt.Sprintf("git branch error: %v", err))
+		return m.setStatus("Cannot list branches, see log")
+	}
+	current, _ := gitInfoAt(root)
+	m.selectedPrj = proj
+	m.branchRoot = root
+	m.branchCurrent = current
+	m.branches = branches
+	m.branchCursor = 0
+	for i, b := range branches {
+		if b == current {
+			m.branchCursor = i
+		}
+	}
+	m.state = StateBranches
+	return nil
+}
+
+// refreshRepoItems re-reads git info for every project inside root.
+func (m model) refreshRepoItems(root string) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, it := range m.list.Items() {
+		if p, ok := it.(ProjectInfo); ok && samePath(findGitRoot(projectDir(p)), root) {
+			cmds = append(cmds, gitInfoCmd(p, m.scanGen))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+type statusClearMsg struct{ text string }
+
+// statusMsg lets background commands report a transient status line message.
+type statusMsg struct{ text string }
+
+// setStatus shows a transient message in the status line for a few seconds.
+func (m *model) setStatus(text string) tea.Cmd {
+	m.statusMsg = text
+	return tea.Tick(4*time.Second, func(time.Time) tea.Msg {
+		return statusClearMsg{text: text}
+	})
+}
+
+type tickMsg time.Time
+
+type updateCheckMsg struct {
+	version string
+	url     string
+	err     error
+}
+type updateDoneMsg struct{ err error }
+
+func checkUpdateCmd() tea.Cmd {
+	return func() tea.Msg {
+		ver, url, err := checkUpdate()
+		return updateCheckMsg{version: ver, url: url, err: err}
+	}
+}
+
+func waitForNextUpdateCheck(interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+type downloadProgressMsg struct {
+	read, total int64
+	next        <-chan tea.Msg
+}
+
+// performUpdateCmd runs doUpdate in the background. It yields
+// downloadProgressMsg while downloading and updateDoneMsg at the end; each
+// progress message carries the channel to wait on for the next one.
+func performUpdateCmd(url string) tea.Cmd {
+	return func() tea.Msg {
+		ch := make(chan tea.Msg, 1)
+		go func() {
+			err := doUpdate(url, func(read, total int64) {
+				select {
+				case ch <- downloadProgressMsg{read: read, total: total, next: ch}:
+				default: // the UI has not caught up, skip this tick
+				}
+			})
+			ch <- updateDoneMsg{err: err}
+		}()
+		return <-ch
+	}
+}
+
+func waitForUpdateMsg(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg { return <-ch }
+}
+
+// progressBar renders "██████░░░░ 60%" for read/total, or only the
+// downloaded size when the total is unknown.
+func progressBar(read, total int64, width int) string {
+	if total <= 0 {
+		return humanizeBytes(uint64(read)) + " downloaded"
+	}
+	ratio := min(float64(read)/float64(total), 1)
+	filled := int(ratio * float64(width))
+	bar := lipgloss.NewStyle().Foreground(colPrimary).Render(strings.Repeat("█", filled)) +
+		subTextStyle.Render(strings.Repeat("░", width-filled))
+	return fmt.Sprintf("%s %3.f%% (%s / %s)", bar, ratio*100, humanizeBytes(uint64(read)), humanizeBytes(uint64(total)))
+}
+
+func (m model) updatesDisabled() bool {
+	return m.noUpdate || m.config.DisableUpdateCheck
+}
+
+func (m model) Init() tea.Cmd {
+	cmds := []tea.Cmd{textinput.Blink, runningIDEsCmd(), m.diskFreeCmd(), gitCheckCmd()}
+	if m.updatesDisabled() {
+		WriteLog("Update check disabled")
+	} else {
+		cmds = append(cmds, checkUpdateCmd(), waitForNextUpdateCheck(m.config.updateCheckInterval()))
+	}
+	if m.state == StateLoading {
+		cmds = append(cmds, m.spinner.Tick, scanCmd(m.config))
+	}
+	if m.directMode {
+		cmds = append(cmds, m.spinner.Tick, directLaunchCmd())
+	}
+	return tea.Batch(cmds...)
+}
+
+// directLaunchMsg carries what the pre-launch checks of direct mode need,
+// there is no scan to provide it.
+type directLaunchMsg struct {
+	installed map[string]string
+	running   []RunningIDE
+}
+
+func directLaunchCmd() tea.Cmd {
+	return func() tea.Msg {
+		return directLaunchMsg{installed: FindInstalledIDEs(), running: RunningIDEs()}
+	}
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	defer logPanic("Update")
+	next, cmd := m.update(msg)
+	// Git info is loaded lazily for the rows that became visible.
+	if nm, ok := next.(model); ok && nm.state == StateList {
+		if gitCmd := nm.visibleGitCmds(); gitCmd != nil {
+			return nm, tea.Batch(cmd, gitCmd)
+		}
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if m.state == StateList {
+		m.rememberListPosition()
+	}
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		docStyle = docStyle.MaxWidth(m.width).MaxHeight(m.height)
+		if m.state == StateList {
+			m.list.SetSize(msg.Width-4, msg.Height-4)
+		}
+
+	case launchResultMsg:
+		if msg.seq != m.launchSeq || m.state != StateLaunching {
+			if msg.err != nil {
+				WriteLog(fmt.Sprintf("Cancelled launch finished with error: %v", msg.err))
+			} else {
+				WriteLog("Cancelled launch finished: " + msg.message)
+			}
+			return m, nil
+		}
+
+	case projectVersionMsg:
+		return m, m.checkNewerProject(msg.proj, msg.next)
+
+	case directLaunchMsg:
+		// Shortcuts and --launch go through the same checks as the list.
+		m.installedIDEs = msg.installed
+		m.running = msg.running
+		return m, m.requestLaunch(m.selectedPrj, false)
+
+	case slowLaunchMsg:
+		if msg.seq == m.launchSeq && m.state == StateLaunching {
+			WriteLog("Launch is taking longer than expected: " + m.selectedPrj.Name)
+			m.slowLaunch = true
+		}
+		return m, nil
+
+	case folderPickedMsg:
+		if m.state != StateConfig {
+			return m, nil
+		}
+		if msg.err != nil {
+			m.textInput.Placeholder = "Folder dialog unavailable, type the path"
+		} else if msg.path != "" {
+			m.textInput.SetValue(msg.path)
+			m.textInput.CursorEnd()
+		}
+		return m, nil
+
+	case statusMsg:
+		return m, m.setStatus(msg.text)
+
+	case exportResultMsg:
+		if msg.err != nil {
+			return m, m.setStatus("Export failed, see log")
+		}
+		return m, m.setStatus("Exported to " + msg.path)
+
+	case shortcutResultMsg:
+		if msg.err != nil {
+			return m, m.setStatus("Shortcut failed, see log")
+		}
+		return m, m.setStatus("Shortcut created: " + filepath.Base(msg.path))
+
+	case archiveVersionMsg:
+		if msg.gen != m.scanGen {
+			return m, nil
+		}
+		cmd := m.updateItem(msg.path, func(p *ProjectInfo) {
+			p.Version = msg.version
+			p.VersionPending = false
+			p.Broken = msg.broken
+			p.FlatVersion = msg.flatVersion
+		})
+		if m.config.GroupBy == GroupByVersion || m.config.ProblemSection {
+			return m, tea.Batch(cmd, m.applySort()) // move it to its version group / the problem section
+		}
+		return m, cmd
+
+	case gitCheckMsg:
+		if msg.err != nil {
+			m.gitMissing = true
+			return m, m.setStatus("git not found in PATH, branch and status badges are disabled")
+		}
+		return m, nil
+
+	case entryPointsMsg:
+		if m.state != StateList {
+			return m, nil
+		}
+		if len(msg.entries) == 0 {
+			return m, m.setStatus("No entry points found in " + msg.root)
+		}
+		m.entries = msg.entries
+		m.entryRoot = msg.root
+		m.entryCursor = 0
+		if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+			for i, e := range m.entries {
+				if samePath(e.Path, p.Path) {
+					m.entryCursor = i
+				}
+			}
+		}
+		m.state = StateEntryPoints
+		return m, nil
+
+	case ipcLaunchMsg:
+		proj, err := buildProjectInfoFromPath(msg.path)
+		if err != nil {
+			return m, m.setStatus(fmt.Sprintf("Launch request rejected: %v", err))
+		}
+		if !m.listReady || m.state == StateLaunching || m.state == StateUpdating || m.state == StateLoading {
+			return m, m.setStatus("Busy, ignored launch request for " + proj.Name)
+		}
+		m.returnToList()
+		return m, m.requestLaunch(proj, false)
+
+	case disarmLaunchMsg:
+		if msg.seq == m.armedSeq {
+			m.armedPath = ""
+		}
+		return m, nil
+
+	case gitInfoMsg:
+		if msg.gen != m.scanGen {
+			return m, nil
+		}
+		return m, m.updateItem(msg.path, func(p *ProjectInfo) {
+			p.GitBranch = msg.branch
+			p.Git = msg.status
+			p.GitPending = false
+		})
+
+	case branchSwitchMsg:
+		refresh := m.refreshRepoItems(msg.root)
+		if msg.err != nil {
+			m.fail("git checkout "+msg.branch, msg.err, func(m *model) tea.Cmd {
+				return switchBranchCmd(msg.root, msg.branch, msg.mode)
+			})
+			return m, refresh
+		}
+		return m, tea.Batch(refresh, m.setStatus(msg.message))
+
+	case compareMsg:
+		if samePath(msg.paths[0], m.compare[0].Path) && samePath(msg.paths[1], m.compare[1].Path) {
+			m.comparePending = false
+			m.compareFacts = msg.facts
+		}
+		return m, nil
+
+	case previewMsg:
+		if m.state != StatePreview || !m.previewPending || !samePath(msg.path, m.selectedPrj.Path) {
+			return m, nil
+		}
+		m.previewPending = false
+		if msg.err != nil {
+			WriteLog(fmt.Sprintf("Preview of %s failed: %v", msg.path, msg.err))
+			m.returnToList()
+			return m, m.setStatus("Cannot read archive: " + msg.err.Error())
+		}
+		m.preview.SetContent(msg.content)
+		return m, nil
+
+	case librariesMsg:
+		if samePath(msg.path, m.selectedPrj.Path) {
+			m.libsPending = false
+			m.detailsLibs = msg.libs
+		}
+		return m, nil
+
+	case remoteURLMsg:
+		if samePath(msg.path, m.selectedPrj.Path) {
+			m.remotePending = false
+			m.detailsRemote = msg.url
+		}
+		return m, nil
+
+	case cleanScanMsg:
+		if len(msg.dirs) == 0 {
+			return m, m.setStatus("Nothing to clean in " + msg.proj.Name)
+		}
+		var listed strings.Builder
+		for i, dir := range msg.dirs {
+			if i == MaxCleanListed {
+				fmt.Fprintf(&listed, "… %d more\n", len(msg.dirs)-i)
+				break
+			}
+			if rel, err := filepath.Rel(projectDir(msg.proj), dir); err == nil {
+				dir = rel
+			}
+			listed.WriteString(dir + "\n")
+		}
+		m.confirm(fmt.Sprintf("Delete %d folder(s) in %s and free %s?\n\n%s", len(msg.dirs), msg.proj.Name, humanizeBytes(msg.size), listed.String()),
+			func(m *model) tea.Cmd {
+				if why, busy := projectInUse(msg.proj, m.running, m.config.LockFilePatterns); busy {
+					return m.setStatus("Not cleaned: " + why)
+				}
+				return tea.Batch(cleanCmd(msg.proj, msg.dirs), m.setStatus("Cleaning "+msg.proj.Name+"..."))
+			})
+		return m, nil
+
+	case cleanDoneMsg:
+		if msg.err != nil {
+			m.fail("clean "+msg.proj.Name, msg.err, func(m *model) tea.Cmd {
+				folder, _ := flatFolder(msg.proj)
+				return cleanScanCmd(msg.proj, folder, m.config.CleanDirs)
+			})
+			return m, nil
+		}
+		return m, m.setStatus(fmt.Sprintf("Cleaned %s: %s freed", msg.proj.Name, humanizeBytes(msg.freed)))
+
+	case submodulesInitMsg:
+		refresh := m.refreshRepoItems(msg.root)
+		if msg.err != nil {
+			m.fail("git submodule update --init", msg.err, func(m *model) tea.Cmd {
+				return initSubmodulesCmd(msg.proj, msg.next)
+			})
+			return m, refresh
+		}
+		msg.proj.Git.Submodules = 0
+		return m, tea.Batch(refresh, msg.next(&m, msg.proj))
+
+	case branchCreatedMsg:
+		refresh := m.refreshRepoItems(msg.root)
+		if msg.err != nil {
+			m.fail("git checkout -b "+msg.name, msg.err, func(m *model) tea.Cmd {
+				return createBranchCmd(msg.root, msg.name)
+			})
+			return m, refresh
+		}
+		m.selectedPrj.GitBranch = msg.name
+		return m, tea.Batch(refresh, m.requestLaunch(m.selectedPrj, false))
+
+	case diskFreeMsg:
+		for vol, free := range msg.free {
+			if free < LowDiskSpace && m.diskFree[vol] >= LowDiskSpace {
+				WriteLog(fmt.Sprintf("Low disk space on %s: %s free", vol, humanizeBytes(free)))
+			}
+		}
+		m.diskFree = msg.free
+		return m, tea.Tick(DiskFreeRefresh, func(time.Time) tea.Msg { return diskRefreshMsg{} })
+
+	case diskRefreshMsg:
+		return m, m.diskFreeCmd()
+
+	case runningIDEsMsg:
+		if running := runningSummary(msg.running); running != runningSummary(m.running) {
+			var procs []string
+			for _, r := range msg.running {
+				procs = append(procs, fmt.Sprintf("PID %d v%s %s", r.PID, r.Version, r.Project))
+			}
+			WriteLog("Running IDEs: " + strings.Join(procs, "; "))
+		}
+		m.running = msg.running
+		return m, runningIDEsTimer()
+
+	case gitRefreshMsg:
+		if msg.gen != m.scanGen {
+			return m, nil
+		}
+		// Visible rows are re-read right away (Update), the rest when shown.
+		clear(m.gitRequested)
+		return m, gitRefreshTimer(m.scanGen)
+
+	case statusClearMsg:
+		if m.statusMsg == msg.text {
+			m.statusMsg = ""
+		}
+		return m, nil
+
+	case tickMsg:
+		return m, tea.Batch(checkUpdateCmd(), waitForNextUpdateCheck(m.config.updateCheckInterval()))
+
+	case updateCheckMsg:
+		if msg.err == nil && msg.version != "" && m.config.AutoUpdate {
+			if !m.autoUpdating && !m.restartPending && m.state != StateUpdating {
+				WriteLog(fmt.Sprintf("Auto-update: downloading %s", msg.version))
+				m.updateVer = msg.version
+				m.updateURL = msg.url
+				m.autoUpdating = true
+				return m, performUpdateCmd(msg.url)
+			}
+		} else if msg.err == nil && msg.version != "" {
+			if m.state != StateLaunching && m.state != StateUpdating && m.state != StateUpdateFound {
+				m.updateVer = msg.version
+				m.updateURL = msg.url
+				m.state = StateUpdateFound
+			}
+		}
+
+	case scanDoneMsg:
+		// Handled in every state: a dialog opened during the scan (the update
+		// prompt) stays on top, with the list ready behind it.
+		prev := m.state
+		m.applyScan(msg.scanResult)
+		m.restoreResumeState()
+		if prev != StateLoading {
+			m.state = prev
+		}
+		return m, m.backgroundScanCmds()
+
+	case downloadProgressMsg:
+		m.dlRead, m.dlTotal = msg.read, msg.total
+		return m, waitForUpdateMsg(msg.next)
+
+	case updateDoneMsg:
+		m.dlRead, m.dlTotal = 0, 0
+		if m.autoUpdating {
+			m.autoUpdating = false
+			if msg.err != nil {
+				WriteLog(fmt.Sprintf("Auto-update to %s failed: %v", m.updateVer, msg.err))
+				return m, nil
+			}
+			WriteLog(fmt.Sprintf("Auto-update to %s applied, restarting", m.updateVer))
+			notify := notifyCmd(m.config, "LazyPLCNext updated", "Version "+m.updateVer)
+			if m.state == StateLaunching {
+				m.restartPending = true
+				return m, notify
+			}
+			m.restart()
+			// Quit after the toast so it is not cut off, without blocking Update.
+			return m, tea.Sequence(notify, tea.Quit)
+		}
+		if msg.err != nil {
+			url := m.updateURL
+			m.fail("update to "+m.updateVer, msg.err, func(m *model) tea.Cmd {
+				m.state = StateUpdating
+				return tea.Batch(m.spinner.Tick, performUpdateCmd(url))
+			})
+		} else {
+			m.logMsg = "Update successful! Please restart."
+			m.state = StateSuccess
+			return m, notifyCmd(m.config, "LazyPLCNext updated", "Version "+m.updateVer)
+		}
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		if m.state == StateList && msg.String() == "q" && m.list.FilterState() != list.Filtering {
+			return m, tea.Quit
+		}
+
+		if m.state == StateSuccess {
+			if strings.Contains(m.logMsg, "Update successful") && (msg.String() == "r" || msg.String() == "R") {
+				m.restart()
+				return m, tea.Quit
+			}
+			switch msg.String() {
+			case "esc", "enter", "q", " ":
+				if m.directMode {
+					return m, tea.Quit
+				}
+				m.returnToList()
+				return m, nil
+			}
+		}
+	}
+
+	switch m.state {
+	case StateUpdateFound:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "y", "Y", "enter":
+				m.state = StateUpdating
 				return m, tea.Batch(m.spinner.Tick, performUpdateCmd(m.updateURL))
 			case "n", "N", "esc":
 				if m.directMode {
 					return m, tea.Quit
 				}
-				m.state = StateList
+				m.returnToList()
+				return m, nil
+			}
+		}
+		return m, nil
+
+	case StateUpdating:
+		var spinCmd tea.Cmd
+		m.spinner, spinCmd = m.spinner.Update(msg)
+		return m, spinCmd
+
+	case StateLoading:
+		if key, ok := msg.(tea.KeyMsg); ok && (key.String() == "q" || key.String() == "esc") {
+			return m, tea.Quit
+		}
+		var spinCmd tea.Cmd
+		m.spinner, spinCmd = m.spinner.Update(msg)
+		return m, spinCmd
+
+	case StateConfig:
+		if key, ok := msg.(tea.KeyMsg); ok && key.String() == "ctrl+o" {
+			return m, pickFolderCmd(strings.TrimSpace(m.textInput.Value()))
+		}
+		if key, ok := msg.(tea.KeyMsg); ok && key.Type == tea.KeyEsc {
+			if len(m.unavailableDirs) > 0 {
+				m.state = StateWorkDirs
+				return m, nil
+			}
+			if len(m.config.WorkDirs) > 0 {
+				m.returnToList()
+				return m, nil
+			}
+		}
+
+		var tiCmd tea.Cmd
+		m.textInput, tiCmd = m.textInput.Update(msg)
+		m.textInput.SetSuggestions(pathSuggestions(m.textInput.Value()))
+		if key, ok := msg.(tea.KeyMsg); ok {
+			m.configErr = ""
+			path := strings.TrimSpace(m.textInput.Value())
+			if key.Type == tea.KeyEnter && path != "" {
+				if err := validateWorkDir(path); err != nil {
+					WriteLog(fmt.Sprintf("Work dir rejected: %v", err))
+					m.configErr = err.Error()
+					return m, tiCmd
+				}
+				m.config.WorkDirs = []string{path}
+				m.unavailableDirs = nil
+				saveConfig(m.config)
+				return m, m.startScan()
+			}
+		}
+		return m, tiCmd
+
+	case StateList:
+		if mouse, ok := msg.(tea.MouseMsg); ok && mouse.Action == tea.MouseActionPress {
+			switch mouse.Button {
+			case tea.MouseButtonWheelUp:
+				m.list.CursorUp()
+				m.skipGroupHeader(true)
+			case tea.MouseButtonWheelDown:
+				m.list.CursorDown()
+				m.skipGroupHeader(false)
+			case tea.MouseButtonLeft:
+				if idx, ok := m.listItemAt(mouse.Y); ok && m.list.FilterState() != list.Filtering {
+					// A click on the already selected project acts like Enter.
+					if idx == m.list.Index() {
+						if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+							return m, m.defaultAction(p)
+						}
+					}
+					m.list.Select(idx)
+				}
+			}
+			return m, nil
+		}
+		if key, ok := msg.(tea.KeyMsg); ok {
+			if m.list.FilterState() != list.Filtering {
+				if key.String() == "c" {
+					m.state = StateConfig
+					currentPath := ""
+					if len(m.config.WorkDirs) > 0 {
+						currentPath = m.config.WorkDirs[0]
+					}
+					m.textInput.SetValue(currentPath)
+					m.textInput.CursorEnd()
+					m.textInput.Focus()
+					return m, nil
+				}
+				if key.String() == "v" {
+					m.openIDEList()
+					return m, nil
+				}
+				if key.String() == "B" {
+					if m.gitMissing {
+						return m, m.setStatus("git not found in PATH")
+					}
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						return m, m.openBranches(p)
+					}
+				}
+				if key.String() == "." {
+					if m.lastAction == nil {
+						return m, m.setStatus("Nothing to repeat yet")
+					}
+					if target := m.lastAction.launch; target.Path != "" && m.needsArming(target) {
+						return m, m.armLaunch(target, ".")
+					}
+					WriteLog("Repeating: " + m.lastAction.desc)
+					return m, m.lastAction.run(&m)
+				}
+				if key.String() == " " {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						k := pathKey(p.Path)
+						if m.marked[k] {
+							delete(m.marked, k)
+						} else {
+							m.marked[k] = true
+						}
+						return m, m.setStatus(fmt.Sprintf("%d project(s) marked", len(m.marked)))
+					}
+				}
+				if key.String() == "W" {
+					if len(m.marked) == 0 {
+						return m, m.setStatus("Mark projects with Space first")
+					}
+					ti := textinput.New()
+					ti.Placeholder = "session name"
+					ti.CharLimit = 60
+					ti.Width = 40
+					ti.PromptStyle = focusedInputStyle
+					ti.TextStyle = focusedInputStyle
+					ti.Focus()
+					m.sessionInput = ti
+					m.state = StateSessionName
+					return m, textinput.Blink
+				}
+				if key.String() == "w" {
+					m.openSessions()
+					return m, nil
+				}
+				if key.String() == "p" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						if p.Type != TypePCWEX {
+							return m, m.setStatus("Preview is available for .pcwex archives")
+						}
+						m.selectedPrj = p
+						m.preview = viewport.New(max(40, min(m.width-10, 110)), max(5, m.height-10))
+						m.preview.SetContent(subTextStyle.Render("Reading archive…"))
+						m.previewPending = true
+						m.state = StatePreview
+						return m, previewCmd(p.Path)
+					}
+					return m, nil
+				}
+				if key.String() == "e" {
+					m.exportCount = len(m.list.VisibleItems())
+					m.state = StateExport
+					return m, nil
+				}
+				if key.String() == "E" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						editor := m.config.editorCommand()
+						m.remember("open "+p.Name+" in editor", func(*model) tea.Cmd { return openEditorCmd(p, editor) })
+						return m, openEditorCmd(p, editor)
+					}
+				}
+				if key.String() == "o" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						return m, openRepoCmd(p)
+					}
+				}
+				if key.String() == "i" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						return m, m.openDetails(p)
+					}
+					return m, nil
+				}
+				if key.String() == "a" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						archived := m.config.toggleArchived(p.Path)
+						saveConfig(m.config)
+						WriteLog(fmt.Sprintf("Archived %s: %v", p.Path, archived))
+						status := "Unarchived " + p.Name
+						if archived {
+							status = "Archived " + p.Name + " ('H' shows archived projects)"
+						}
+						return m, tea.Batch(m.applySort(), m.setStatus(status))
+					}
+					return m, nil
+				}
+				if key.String() == "X" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						folder, isFlat := flatFolder(p)
+						if !isFlat {
+							return m, m.setStatus("Clean is available for flat projects")
+						}
+						if why, busy := projectInUse(p, m.running, m.config.LockFilePatterns); busy {
+							WriteLog(fmt.Sprintf("Clean of %s refused: %s", p.Path, why))
+							return m, m.setStatus("Not cleaned: " + why)
+						}
+						return m, tea.Batch(cleanScanCmd(p, folder, m.config.CleanDirs), m.setStatus("Measuring build folders..."))
+					}
+					return m, nil
+				}
+				if key.String() == "C" {
+					var marked []ProjectInfo
+					for _, it := range m.list.Items() {
+						if p, ok := it.(ProjectInfo); ok && m.marked[pathKey(p.Path)] {
+							marked = append(marked, p)
+						}
+					}
+					if len(marked) != 2 {
+						return m, m.setStatus(fmt.Sprintf("Mark exactly two projects with Space to compare (%d marked)", len(marked)))
+					}
+					m.compare = [2]ProjectInfo{marked[0], marked[1]}
+					m.compareFacts = [2]compareFacts{}
+					m.comparePending = true
+					m.state = StateCompare
+					return m, compareCmd(marked[0], marked[1])
+				}
+				if key.String() == "H" {
+					m.showArchived = !m.showArchived
+					status := "Archived projects: hidden"
+					if m.showArchived {
+						status = "Archived projects: shown"
+					}
+					return m, tea.Batch(m.applySort(), m.setStatus(status))
+				}
+				if key.String() == "A" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						// The IDE of another account is a separate instance,
+						// checkRunningIDE does not apply.
+						return m, m.checkNewerProject(p, func(m *model, p ProjectInfo) tea.Cmd {
+							return m.checkProjectLock(p, (*model).openRunAs)
+						})
+					}
+					return m, nil
+				}
+				if key.String() == "L" {
+					if err := openURL(logFilePath()); err != nil {
+						return m, m.setStatus("Cannot open log: " + err.Error())
+					}
+					return m, nil
+				}
+				if key.String() == "#" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						m.selectedPrj = p
+						ti := textinput.New()
+						ti.Placeholder = "tags, separated by spaces"
+						ti.CharLimit = 200
+						ti.Width = 40
+						ti.PromptStyle = focusedInputStyle
+						ti.TextStyle = focusedInputStyle
+						ti.SetValue(strings.Join(m.config.Tags[p.Path], " "))
+						ti.Focus()
+						m.tagInput = ti
+						m.state = StateTags
+						return m, textinput.Blink
+					}
+					return m, nil
+				}
+				if key.String() == "t" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						return m, tea.Batch(m.setStatus("Looking for entry points…"), entryPointsCmd(p, m.config))
+					}
+					return m, nil
+				}
+				if key.String() == "F" {
+					switch m.gitFilter {
+					case "":
+						m.gitFilter = GitFilterOnly
+					case GitFilterOnly:
+						m.gitFilter = GitFilterNone
+					default:
+						m.gitFilter = ""
+					}
+					status := "Git filter: off"
+					if m.gitFilter != "" {
+						status = "Showing only projects: " + m.gitFilter
+					}
+					return m, tea.Batch(m.applySort(), m.setStatus(status))
+				}
+				if key.String() == "P" {
+					m.config.ProblemSection = !m.config.ProblemSection
+					saveConfig(m.config)
+					m.list.SetDelegate(m.delegate())
+					status := "Problem projects section: off"
+					if m.config.ProblemSection {
+						status = "Problem projects section: on"
+					}
+					return m, tea.Batch(m.applySort(), m.setStatus(status))
+				}
+				if key.String() == "V" {
+					if m.config.GroupBy == GroupByVersion {
+						m.config.GroupBy = ""
+					} else {
+						m.config.GroupBy = GroupByVersion
+					}
+					saveConfig(m.config)
+					status := "Grouping: off"
+					if m.config.GroupBy != "" {
+						status = "Grouping: " + m.config.GroupBy
+					}
+					return m, tea.Batch(m.applySort(), m.setStatus(status))
+				}
+				if key.String() == "s" {
+					if m.config.SortMode == SortByLaunches {
+						m.config.SortMode = SortByName
+					} else {
+						m.config.SortMode = SortByLaunches
+					}
+					saveConfig(m.config)
+					return m, tea.Batch(m.applySort(), m.setStatus("Sort: "+m.config.SortMode))
+				}
+				if key.String() == "R" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						return m, m.requestLaunch(p, true)
+					}
+				}
+				if key.String() == "S" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						return m, createShortcutCmd(p)
+					}
+				}
+				if key.String() == "y" {
+					if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+						line, err := launchCommandLine(p, m.config)
+						if err != nil {
+							return m, m.setStatus(err.Error())
+						}
+						if err := clipboard.WriteAll(line); err != nil {
+							WriteLog(fmt.Sprintf("Clipboard error: %v", err))
+							return m, m.setStatus("Clipboard unavailable")
+						}
+						WriteLog("Copied launch command: " + line)
+						return m, m.setStatus("Launch command copied")
+					}
+				}
+			}
+			if key.Type == tea.KeyEnter && m.list.FilterState() != list.Filtering {
+				if i, ok := m.list.SelectedItem().(ProjectInfo); ok {
+					return m, m.defaultAction(i)
+				}
+			}
+			if key.String() == "O" && m.list.FilterState() != list.Filtering {
+				if i, ok := m.list.SelectedItem().(ProjectInfo); ok {
+					return m, openFolderCmd(i)
+				}
+			}
+			if key.String() == "x" && m.list.FilterState() != list.Filtering {
+				if i, ok := m.list.SelectedItem().(ProjectInfo); ok {
+					if m.needsArming(i) {
+						return m, m.armLaunch(i, "x")
+					}
+					return m, m.requestLaunch(i, false)
+				}
+			}
+		}
+		var listCmd tea.Cmd
+		wasFiltering := m.list.FilterState() == list.Filtering
+		m.list, listCmd = m.list.Update(msg)
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "up", "k", "pgup", "left", "h", "b", "u", "home", "g":
+				m.skipGroupHeader(true)
+			default:
+				m.skipGroupHeader(false)
+			}
+		}
+		// Only when the filter was just accepted, never while typing it.
+		if wasFiltering && m.list.FilterState() == list.FilterApplied && m.config.AutoOpenSingleMatch {
+			if p, ok := m.singleVisibleProject(); ok {
+				WriteLog("Filter narrowed to one project: " + p.Name)
+				return m, tea.Batch(listCmd, m.openDetails(p))
+			}
+		}
+		return m, listCmd
+
+	case StateBranches:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "up", "k":
+				if m.branchCursor > 0 {
+					m.branchCursor--
+				}
+			case "down", "j":
+				if m.branchCursor < len(m.branches)-1 {
+					m.branchCursor++
+				}
+			case "enter":
+				if len(m.branches) == 0 {
+					return m, nil
+				}
+				target := m.branches[m.branchCursor]
+				if target == m.branchCurrent {
+					m.returnToList()
+					return m, nil
+				}
+				return m, m.checkoutBranch(m.branchRoot, m.branchCurrent, target)
+			case "n":
+				ti := textinput.New()
+				ti.Placeholder = "feature/new-task"
+				ti.CharLimit = 100
+				ti.Width = 40
+				ti.PromptStyle = focusedInputStyle
+				ti.TextStyle = focusedInputStyle
+				ti.Focus()
+				m.branchInput = ti
+				m.state = StateNewBranch
+				return m, textinput.Blink
+			case "esc", "q":
+				m.returnToList()
+			}
+		}
+		return m, nil
+
+	case StateNewBranch:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.Type {
+			case tea.KeyEsc:
+				m.state = StateBranches
+				return m, nil
+			case tea.KeyEnter:
+				name := strings.TrimSpace(m.branchInput.Value())
+				if err := validateBranchName(name); err != nil {
+					return m, m.setStatus(err.Error())
+				}
+				for _, b := range m.branches {
+					if b == name {
+						return m, m.setStatus("Branch " + name + " already exists, pick it from the list")
+					}
+				}
+				m.returnToList()
+				return m, createBranchCmd(m.branchRoot, name)
+			}
+		}
+		var tiCmd tea.Cmd
+		m.branchInput, tiCmd = m.branchInput.Update(msg)
+		return m, tiCmd
+
+	case StateStashPrompt:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			mode := StashNone
+			switch key.String() {
+			case "s":
+				mode = StashPop
+			case "k":
+				mode = StashKeep
+			case "c":
+				mode = StashNone
+			case "esc", "n":
+				m.returnToList()
+				return m, nil
+			default:
 				return m, nil
 			}
+			m.returnToList()
+			return m, switchBranchCmd(m.branchRoot, m.pendingBranch, mode)
 		}
 		return m, nil
 
-	case StateUpdating:
-		var spinCmd tea.Cmd
-		m.spinner, spinCmd = m.spinner.Update(msg)
-		return m, spinCmd
+	case StatePreview:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "esc", "q", "p":
+				m.returnToList()
+				return m, nil
+			case "enter":
+				if m.needsArming(m.selectedPrj) {
+					return m, m.armLaunch(m.selectedPrj, "Enter")
+				}
+				return m, m.requestLaunch(m.selectedPrj, false)
+			}
+		}
+		var vpCmd tea.Cmd
+		m.preview, vpCmd = m.preview.Update(msg)
+		return m, vpCmd
 
-	case StateConfig:
-		if key, ok := msg.(tea.KeyMsg); ok && key.Type == tea.KeyEsc {
-			if len(m.config.WorkDirs) > 0 {
-				m.state = StateList
+	case StateRunAs:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.Type {
+			case tea.KeyEsc:
+				m.runAsPass.SetValue("")
+				m.returnToList()
 				return m, nil
+			case tea.KeyTab, tea.KeyShiftTab, tea.KeyUp, tea.KeyDown:
+				if m.runAsUser.Focused() {
+					m.runAsUser.Blur()
+					m.runAsPass.Focus()
+				} else {
+					m.runAsPass.Blur()
+					m.runAsUser.Focus()
+				}
+				return m, textinput.Blink
+			case tea.KeyEnter:
+				account := strings.TrimSpace(m.runAsUser.Value())
+				if account == "" {
+					return m, nil
+				}
+				if m.runAsUser.Focused() {
+					m.runAsUser.Blur()
+					m.runAsPass.Focus()
+					return m, textinput.Blink
+				}
+				password := m.runAsPass.Value()
+				m.runAsPass.SetValue("")
+				proj := m.selectedPrj
+				m.state = StateLaunching
+				m.launchSeq++
+				m.slowLaunch = false
+				m.launchRetry = nil // the password is gone, ask again
+				return m, tea.Batch(m.spinner.Tick, launchAsUserCmd(proj, m.config, account, password, m.launchSeq))
 			}
 		}
+		var cmd tea.Cmd
+		if m.runAsUser.Focused() {
+			m.runAsUser, cmd = m.runAsUser.Update(msg)
+		} else {
+			m.runAsPass, cmd = m.runAsPass.Update(msg)
+		}
+		return m, cmd
 
+	case StateTags:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.Type {
+			case tea.KeyEsc:
+				m.returnToList()
+				return m, nil
+			case tea.KeyEnter:
+				p := m.selectedPrj
+				tags := parseTags(m.tagInput.Value())
+				if len(tags) == 0 {
+					delete(m.config.Tags, p.Path)
+				} else {
+					if m.config.Tags == nil {
+						m.config.Tags = make(map[string][]string)
+					}
+					m.config.Tags[p.Path] = tags
+				}
+				if err := saveConfig(m.config); err != nil {
+					WriteLog(fmt.Sprintf("Failed to save tags: %v", err))
+				}
+				WriteLog(fmt.Sprintf("Tags of %s: %v", p.Path, tags))
+				m.returnToList()
+				return m, m.updateItem(p.Path, func(p *ProjectInfo) { p.Tags = tags })
+			}
+		}
 		var tiCmd tea.Cmd
-		m.textInput, tiCmd = m.textInput.Update(msg)
-		if key, ok := msg.(tea.KeyMsg); ok && key.Type == tea.KeyEnter {
-			path := strings.TrimSpace(m.textInput.Value())
-			if path != "" {
-				if info, err := os.Stat(path); err == nil && info.IsDir() {
-					m.config.WorkDirs = []string{path}
-					saveConfig(m.config)
-					m.reloadList()
+		m.tagInput, tiCmd = m.tagInput.Update(msg)
+		return m, tiCmd
+
+	case StateSessionName:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.Type {
+			case tea.KeyEsc:
+				m.returnToList()
+				return m, nil
+			case tea.KeyEnter:
+				name := strings.TrimSpace(m.sessionInput.Value())
+				if name == "" {
 					return m, nil
-				} else {
-					m.textInput.Placeholder = "Invalid directory!"
-					m.textInput.SetValue("")
 				}
+				var paths []string
+				for _, it := range m.list.Items() {
+					if p, ok := it.(ProjectInfo); ok && m.marked[pathKey(p.Path)] {
+						paths = append(paths, p.Path)
+					}
+				}
+				if m.config.Sessions == nil {
+					m.config.Sessions = make(map[string][]string)
+				}
+				m.config.Sessions[name] = paths
+				if err := saveConfig(m.config); err != nil {
+					WriteLog(fmt.Sprintf("Failed to save session: %v", err))
+				}
+				WriteLog(fmt.Sprintf("Session %q saved with %d projects", name, len(paths)))
+				clear(m.marked)
+				m.returnToList()
+				return m, m.setStatus(fmt.Sprintf("Session %q saved (%d projects)", name, len(paths)))
 			}
 		}
+		var tiCmd tea.Cmd
+		m.sessionInput, tiCmd = m.sessionInput.Update(msg)
 		return m, tiCmd
 
-	case StateList:
+	case StateSessions:
 		if key, ok := msg.(tea.KeyMsg); ok {
-			if m.list.FilterState() != list.Filtering {
-				if key.String() == "c" {
-					m.state = StateConfig
-					currentPath := ""
-					if len(m.config.WorkDirs) > 0 {
-						currentPath = m.config.WorkDirs[0]
+			switch key.String() {
+			case "up", "k":
+				if m.sessionCursor > 0 {
+					m.sessionCursor--
+				}
+			case "down", "j":
+				if m.sessionCursor < len(m.sessionNames)-1 {
+					m.sessionCursor++
+				}
+			case "x", "delete":
+				if len(m.sessionNames) > 0 {
+					name := m.sessionNames[m.sessionCursor]
+					delete(m.config.Sessions, name)
+					saveConfig(m.config)
+					WriteLog(fmt.Sprintf("Session %q deleted", name))
+					m.openSessions()
+				}
+			case "enter":
+				if len(m.sessionNames) > 0 {
+					name := m.sessionNames[m.sessionCursor]
+					if m.needsArming(sessionTarget(name)) {
+						return m, m.armLaunch(sessionTarget(name), "Enter")
 					}
-					m.textInput.SetValue(currentPath)
-					m.textInput.CursorEnd()
-					m.textInput.Focus()
+					return m, m.openSession(name)
+				}
+			case "esc", "q", "w":
+				m.returnToList()
+			}
+		}
+		return m, nil
+
+	case StateEntryPoints:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "up", "k":
+				if m.entryCursor > 0 {
+					m.entryCursor--
+				}
+			case "down", "j":
+				if m.entryCursor < len(m.entries)-1 {
+					m.entryCursor++
+				}
+			case "enter":
+				e := m.entries[m.entryCursor]
+				if m.needsArming(e) {
+					return m, m.armLaunch(e, "Enter")
+				}
+				WriteLog("Launching entry point: " + e.Path)
+				m.returnToList()
+				return m, tea.Batch(m.setStatus("Entry point: "+entryLabel(e, m.entryRoot)), m.requestLaunch(e, false))
+			case "esc", "q", "t":
+				m.returnToList()
+			}
+		}
+		return m, nil
+
+	case StateWorkDirs:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "r":
+				m.unavailableDirs = unavailableWorkDirs(m.config.WorkDirs)
+				if len(m.unavailableDirs) > 0 {
+					return m, nil
+				}
+				return m, m.startScan()
+			case "enter":
+				if len(m.unavailableDirs) == len(m.config.WorkDirs) {
 					return m, nil
 				}
+				// Keep the missing dirs in the config, they come back with the drive.
+				m.unavailableDirs = nil
+				return m, m.startScan()
+			case "c":
+				m.state = StateConfig
+				return m, nil
+			case "q", "ctrl+c":
+				return m, tea.Quit
 			}
-			if key.Type == tea.KeyEnter && m.list.FilterState() != list.Filtering {
-				if i, ok := m.list.SelectedItem().(ProjectInfo); ok {
-					m.selectedPrj = i
-					m.state = StateLaunching
-					return m, tea.Batch(m.spinner.Tick, launchProjectCmd(m.selectedPrj))
+		}
+		return m, nil
+
+	case StateExport:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			var format string
+			switch key.String() {
+			case "c":
+				format = FormatCSV
+			case "j":
+				format = FormatJSON
+			case "esc", "q":
+				m.returnToList()
+				return m, nil
+			default:
+				return m, nil
+			}
+			var projects []ProjectInfo
+			for _, it := range m.list.VisibleItems() {
+				if p, ok := it.(ProjectInfo); ok {
+					projects = append(projects, p)
 				}
 			}
+			m.returnToList()
+			return m, exportCmd(projects, format)
 		}
-		var listCmd tea.Cmd
-		m.list, listCmd = m.list.Update(msg)
-		return m, listCmd
+		return m, nil
+
+	case StateDetails:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "enter":
+				if m.needsArming(m.selectedPrj) {
+					return m, m.armLaunch(m.selectedPrj, "Enter")
+				}
+				return m, m.requestLaunch(m.selectedPrj, false)
+			case "c":
+				if m.detailsRemote == "" {
+					return m, m.setStatus("No remote to copy")
+				}
+				if err := clipboard.WriteAll(m.detailsRemote); err != nil {
+					WriteLog(fmt.Sprintf("Clipboard error: %v", err))
+					return m, m.setStatus("Clipboard unavailable")
+				}
+				return m, m.setStatus("Remote URL copied")
+			case "o":
+				if m.detailsRemote != "" {
+					return m, openRepoCmd(m.selectedPrj)
+				}
+			case "esc", "q", "i":
+				m.returnToList()
+			}
+		}
+		return m, nil
+
+	case StateCompare:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "esc", "q", "C":
+				m.returnToList()
+			}
+		}
+		return m, nil
+
+	case StateConfirm:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "y", "Y":
+				onYes := m.confirmYes
+				m.confirmYes = nil
+				m.returnToList()
+				if onYes != nil {
+					return m, onYes(&m)
+				}
+			case "n", "N", "esc":
+				m.confirmYes = nil
+				if m.directMode {
+					return m, tea.Quit
+				}
+				m.returnToList()
+			}
+		}
+		return m, nil
+
+	case StateIDEList:
+		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "up", "k":
+				if m.ideCursor > 0 {
+					m.ideCursor--
+				}
+			case "down", "j":
+				if m.ideCursor < len(m.ideVersions)-1 {
+					m.ideCursor++
+				}
+			case "d", " ":
+				if len(m.ideVersions) > 0 {
+					ver := m.ideVersions[m.ideCursor]
+					if m.config.DefaultIDEVersion == ver {
+						m.config.DefaultIDEVersion = ""
+					} else {
+						m.config.DefaultIDEVersion = ver
+					}
+					saveConfig(m.config)
+					WriteLog(fmt.Sprintf("Default IDE version set to %q", m.config.DefaultIDEVersion))
+				}
+			case "p":
+				if len(m.ideVersions) > 0 && m.ideFor.Path != "" {
+					ver := m.ideVersions[m.ideCursor]
+					if m.config.ProjectIDEVersion[m.ideFor.Path] == ver {
+						delete(m.config.ProjectIDEVersion, m.ideFor.Path)
+					} else {
+						if m.config.ProjectIDEVersion == nil {
+							m.config.ProjectIDEVersion = make(map[string]string)
+						}
+						m.config.ProjectIDEVersion[m.ideFor.Path] = ver
+					}
+					saveConfig(m.config)
+					WriteLog(fmt.Sprintf("IDE version for %s set to %q", m.ideFor.Name, m.config.ProjectIDEVersion[m.ideFor.Path]))
+				}
+			case "enter":
+				if len(m.ideVersions) > 0 {
+					ver := m.ideVersions[m.ideCursor]
+					WriteLog("Starting IDE without project: v" + ver)
+					ide := ProjectInfo{Name: "PLCnext Engineer " + ver, Version: ver}
+					m.remember("start IDE v"+ver, func(m *model) tea.Cmd { return m.startLaunch(ide, false) })
+					return m, m.startLaunch(ide, false)
+				}
+			case "esc", "q", "v":
+				m.returnToList()
+			}
+		}
+		return m, nil
 
 	case StateLaunching:
+		if key, ok := msg.(tea.KeyMsg); ok && key.Type == tea.KeyEsc {
+			// The IDE process (if already started) keeps running, only the UI is released.
+			WriteLog("Launch wait cancelled by user: " + m.selectedPrj.Name)
+			m.launchSeq++
+			if m.restartPending {
+				m.restart()
+				return m, tea.Quit
+			}
+			if m.directMode {
+				return m, tea.Quit
+			}
+			m.returnToList()
+			return m, m.setStatus("Launch wait cancelled")
+		}
 		var spinCmd tea.Cmd
 		m.spinner, spinCmd = m.spinner.Update(msg)
 		if res, ok := msg.(launchResultMsg); ok {
+			if m.restartPending {
+				m.restart()
+				return m, tea.Quit
+			}
 			if res.err != nil {
-				m.err = res.err
-				m.state = StateError
+				m.launchErrors++
+				m.fail("launch "+m.selectedPrj.Name, res.err, m.launchRetry)
 			} else {
 				m.logMsg = res.message
 				m.state = StateSuccess
+				m.recordLaunch(m.selectedPrj)
+				return m, tea.Batch(spinCmd, notifyCmd(m.config, "PLCnext Engineer started", m.selectedPrj.Name))
 			}
 		}
 		return m, spinCmd
 
 	case StateError:
 		if key, ok := msg.(tea.KeyMsg); ok {
+			switch key.String() {
+			case "up", "k":
+				if m.errScroll > 0 {
+					m.errScroll--
+				}
+				return m, nil
+			case "down", "j":
+				if m.errScroll < len(m.errorLines())-1 {
+					m.errScroll++
+				}
+				return m, nil
+			case "c":
+				if err := clipboard.WriteAll(m.errOp + ": " + m.err.Error()); err != nil {
+					WriteLog(fmt.Sprintf("Clipboard error: %v", err))
+					return m, m.setStatus("Clipboard unavailable")
+				}
+				return m, m.setStatus("Error copied")
+			case "l":
+				if err := openURL(logFilePath()); err != nil {
+					return m, m.setStatus("Cannot open log: " + err.Error())
+				}
+				return m, nil
+			case "r":
+				if m.errRetry != nil {
+					retry := m.errRetry
+					m.errRetry = nil
+					WriteLog("Retrying: " + m.errOp)
+					if !m.directMode {
+						m.returnToList()
+					}
+					return m, retry(&m)
+				}
+				return m, nil
+			}
 			if key.Type != tea.KeyNull {
 				if m.directMode {
 					return m, tea.Quit
 				}
-				m.state = StateList
+				m.returnToList()
 				return m, nil
 			}
 		}
@@ -858,13 +5496,26 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // ======================================================================================
 
 func (m model) View() string {
+	defer logPanic("View")
 	centerContent := func(content string) string {
+		if m.inline {
+			// Do not fill the whole terminal, it would push the history out.
+			return lipgloss.PlaceHorizontal(m.width, lipgloss.Center, content)
+		}
 		return lipgloss.Place(m.width, m.height,
 			lipgloss.Center, lipgloss.Center,
 			content)
 	}
 
 	switch m.state {
+	case StateLoading:
+		rows := []string{m.spinner.View() + " Scanning projects...", ""}
+		for _, dir := range m.config.WorkDirs {
+			rows = append(rows, subTextStyle.Render(shortenPath(dir, max(20, min(60, m.width-12)))))
+		}
+		rows = append(rows, "", subTextStyle.Render("q: quit"))
+		return centerContent(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+
 	case StateUpdateFound:
 		ui := lipgloss.JoinVertical(lipgloss.Center,
 			titleStyle.Render(" UPDATE AVAILABLE "),
@@ -872,6 +5523,7 @@ func (m model) View() string {
 			fmt.Sprintf("New version: %s", lipgloss.NewStyle().Foreground(colPrimary).Bold(true).Render(m.updateVer)),
 			fmt.Sprintf("Current version: %s", AppVersion),
 			"\n",
+			subTextStyle.Render("The launcher restarts after the update, selection, filter and marks are kept."),
 			subTextStyle.Render("Download and install now? (y/n)"),
 		)
 		return centerContent(boxStyle.Render(ui))
@@ -880,6 +5532,8 @@ func (m model) View() string {
 		ui := lipgloss.JoinVertical(lipgloss.Center,
 			m.spinner.View()+" Updating...",
 			"\n",
+			progressBar(m.dlRead, m.dlTotal, 30),
+			"\n",
 			subTextStyle.Render("Application will restart automatically"),
 		)
 		return centerContent(boxStyle.Render(ui))
@@ -888,15 +5542,69 @@ func (m model) View() string {
 		ui := lipgloss.JoinVertical(lipgloss.Left,
 			titleStyle.Render(" CONFIGURATION "),
 			"\n",
-			lipgloss.NewStyle().Foreground(colText).Render("Enter project directory path:"),
+			lipgloss.NewStyle().Foreground(colText).Render("Enter project directory path (local or \\\\server\\share):"),
 			m.textInput.View(),
+			lipgloss.NewStyle().Foreground(colError).Render(m.configErr),
 			"\n",
-			subTextStyle.Render("Press Enter to scan • Esc to cancel"),
+			subTextStyle.Render("Tab: complete folder • Ctrl+O: browse • Enter to scan • Esc to cancel"),
 		)
 		return centerContent(boxStyle.Render(ui))
 
 	case StateList:
-		status := fmt.Sprintf("Ver: %s | Projects: %d | 'c': config | 'q': quit", AppVersion, len(m.list.Items()))
+		projCount := fmt.Sprintf("%d", len(m.list.Items()))
+		if m.list.FilterState() != list.Unfiltered {
+			projCount = fmt.Sprintf("%d/%d", len(m.list.VisibleItems()), len(m.list.Items()))
+		}
+		status := fmt.Sprintf("Ver: %s | Projects: %s | 'c': config | 'v': IDEs | 'q': quit", AppVersion, projCount)
+		if summary := m.typeSummary(); summary != "" {
+			status = summary + " | " + status
+		}
+		if m.lastAction != nil {
+			status = "'.': " + m.lastAction.desc + " | " + status
+		}
+		if !m.lastScanTime.IsZero() {
+			scanned := "scanned " + humanizeSince(m.lastScanTime)
+			if time.Since(m.lastScanTime) > ScanStaleAfter {
+				scanned = warnTextStyle.Render(scanned)
+			}
+			status = scanned + " | " + status
+		}
+		if m.gitFilter != "" {
+			status = "Filter: " + m.gitFilter + " | " + status
+		}
+		if m.showArchived {
+			status = "Showing archived | " + status
+		}
+		if m.gitMissing {
+			status = "git: not found | " + status
+		}
+		if m.launchErrors > 0 {
+			status = warnTextStyle.Render(fmt.Sprintf("Launch errors: %d ('L': log)", m.launchErrors)) + " | " + status
+		}
+		if m.scanTimedOut {
+			status = "Scan aborted on timeout, list incomplete | " + status
+		}
+		if len(m.running) > 0 {
+			status = "Running: " + runningSummary(m.running) + " | " + status
+		}
+		if p, ok := m.list.SelectedItem().(ProjectInfo); ok {
+			if disk := m.diskFreeText(p.Path); disk != "" {
+				status = disk + " | " + status
+			}
+		}
+		if m.statusMsg != "" {
+			status = m.statusMsg + " | " + status
+		}
+		if m.autoUpdating {
+			if m.dlTotal > 0 {
+				status = fmt.Sprintf("Updating to %s, restarts when done... %d%% | ", m.updateVer, m.dlRead*100/m.dlTotal) + status
+			} else {
+				status = fmt.Sprintf("Updating to %s, restarts when done... | ", m.updateVer) + status
+			}
+		}
+		if m.updatesDisabled() {
+			status = "Updates: off | " + status
+		}
 		statusView := lipgloss.NewStyle().
 			Foreground(colSubText).
 			Width(m.width - 4).
@@ -908,9 +5616,222 @@ func (m model) View() string {
 			statusView,
 		))
 
+	case StateDetails:
+		return centerContent(boxStyle.Render(m.detailsView()))
+
+	case StateCompare:
+		return centerContent(boxStyle.Render(m.compareView()))
+
+	case StateBranches:
+		rows := []string{titleStyle.Render(" SWITCH BRANCH "), "", subTextStyle.Render(shortenPath(m.branchRoot, 50)), ""}
+		for i, b := range m.branches {
+			label := truncate(b, 50)
+			if b == m.branchCurrent {
+				label += " (current)"
+			}
+			if i == m.branchCursor {
+				rows = append(rows, selectedItemStyle.Render(label))
+			} else {
+				rows = append(rows, itemTitleStyle.Render("  "+label))
+			}
+		}
+		rows = append(rows, "", subTextStyle.Render("Enter: checkout • n: new branch • Esc: back"))
+		return centerContent(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+
+	case StateNewBranch:
+		rows := []string{
+			titleStyle.Render(" NEW BRANCH "),
+			"",
+			fmt.Sprintf("From %s in %s", truncate(m.branchCurrent, 30), shortenPath(m.branchRoot, 40)),
+			m.branchInput.View(),
+		}
+		if m.statusMsg != "" {
+			rows = append(rows, lipgloss.NewStyle().Foreground(colError).Render(m.statusMsg))
+		}
+		rows = append(rows, "", subTextStyle.Render("Enter: create and launch • Esc: back"))
+		return centerContent(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+
+	case StatePreview:
+		ui := lipgloss.JoinVertical(lipgloss.Left,
+			titleStyle.Render(" "+truncate(m.selectedPrj.Name, 50)+" "),
+			"",
+			m.preview.View(),
+			"",
+			subTextStyle.Render(fmt.Sprintf("%3.f%% • ↑/↓ PgUp/PgDn: scroll • Enter: launch • Esc: back", m.preview.ScrollPercent()*100)),
+			subTextStyle.Render(m.statusMsg),
+		)
+		return centerContent(boxStyle.Render(ui))
+
+	case StateRunAs:
+		ui := lipgloss.JoinVertical(lipgloss.Left,
+			titleStyle.Render(" LAUNCH AS ANOTHER USER "),
+			"",
+			m.selectedPrj.Name,
+			"",
+			"Account",
+			m.runAsUser.View(),
+			"Password",
+			m.runAsPass.View(),
+			"",
+			subTextStyle.Render("Tab: switch field • Enter: launch • Esc: cancel"),
+			subTextStyle.Render("The password is passed to Windows only, it is not stored."),
+		)
+		return centerContent(boxStyle.Render(ui))
+
+	case StateTags:
+		ui := lipgloss.JoinVertical(lipgloss.Left,
+			titleStyle.Render(" TAGS "),
+			"",
+			m.selectedPrj.Name,
+			m.tagInput.View(),
+			"",
+			subTextStyle.Render("Enter: save (empty removes all) • Esc: cancel"),
+		)
+		return centerContent(boxStyle.Render(ui))
+
+	case StateSessionName:
+		ui := lipgloss.JoinVertical(lipgloss.Left,
+			titleStyle.Render(" SAVE SESSION "),
+			"",
+			fmt.Sprintf("%d marked project(s)", len(m.marked)),
+			m.sessionInput.View(),
+			"",
+			subTextStyle.Render("Enter: save • Esc: cancel"),
+		)
+		return centerContent(boxStyle.Render(ui))
+
+	case StateSessions:
+		rows := []string{titleStyle.Render(" SESSIONS "), ""}
+		if len(m.sessionNames) == 0 {
+			rows = append(rows, subTextStyle.Render("No sessions yet: mark projects with Space, save with W"))
+		}
+		for i, name := range m.sessionNames {
+			label := fmt.Sprintf("%s (%d)", name, len(m.config.Sessions[name]))
+			if i == m.sessionCursor {
+				rows = append(rows, selectedItemStyle.Render(label))
+			} else {
+				rows = append(rows, itemTitleStyle.Render("  "+label))
+			}
+		}
+		rows = append(rows, "", subTextStyle.Render("Enter: open all • x: delete • Esc: back"), subTextStyle.Render(m.statusMsg))
+		return centerContent(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+
+	case StateEntryPoints:
+		rows := []string{titleStyle.Render(" ENTRY POINTS "), subTextStyle.Render(truncate(m.entryRoot, 60)), ""}
+		for i, e := range m.entries {
+			label := fmt.Sprintf("%s %s  %s", e.Type.Icon(m.config.UseNerdFonts), entryLabel(e, m.entryRoot), subTextStyle.Render("v"+e.Version))
+			if i == m.entryCursor {
+				rows = append(rows, selectedItemStyle.Render(label))
+			} else {
+				rows = append(rows, itemTitleStyle.Render("  "+label))
+			}
+		}
+		rows = append(rows, "", subTextStyle.Render("Enter: launch • Esc: back"))
+		if m.statusMsg != "" {
+			rows = append(rows, subTextStyle.Render(m.statusMsg))
+		}
+		return centerContent(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+
+	case StateWorkDirs:
+		rows := []string{
+			lipgloss.NewStyle().Foreground(colAccent).Bold(true).Render("⚠ WORK DIRECTORIES UNAVAILABLE"),
+			"",
+		}
+		for _, dir := range m.unavailableDirs {
+			label := "  " + shortenPath(dir, 60)
+			if isUNCPath(dir) {
+				label += subTextStyle.Render("  (network share)")
+			}
+			rows = append(rows, label)
+		}
+		help := "r: retry • c: change path • q: quit"
+		if len(m.unavailableDirs) < len(m.config.WorkDirs) {
+			help = "r: retry • Enter: continue with the others • c: change path • q: quit"
+		}
+		rows = append(rows, "", subTextStyle.Render("Network drive not connected? Reconnect it and retry."), "", subTextStyle.Render(help))
+		return centerContent(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+
+	case StateExport:
+		ui := lipgloss.JoinVertical(lipgloss.Left,
+			titleStyle.Render(" EXPORT "),
+			"",
+			fmt.Sprintf("Export %d project(s) shown in the list", m.exportCount),
+			"",
+			"c: CSV",
+			"j: JSON",
+			"",
+			subTextStyle.Render("Esc: cancel"),
+		)
+		return centerContent(boxStyle.Render(ui))
+
+	case StateStashPrompt:
+		ui := lipgloss.JoinVertical(lipgloss.Left,
+			lipgloss.NewStyle().Foreground(colAccent).Bold(true).Render("⚠ UNCOMMITTED CHANGES"),
+			"",
+			fmt.Sprintf("Switching %s → %s", truncate(m.branchCurrent, 30), truncate(m.pendingBranch, 30)),
+			"",
+			"s: stash, checkout and restore the changes",
+			"k: stash and checkout, keep changes in the stash",
+			"c: checkout without stash (git may refuse)",
+			"",
+			subTextStyle.Render("Esc: cancel"),
+		)
+		return centerContent(boxStyle.Render(ui))
+
+	case StateConfirm:
+		title := lipgloss.NewStyle().Foreground(colAccent).Bold(true).Render("⚠ CONFIRM")
+		if m.confirmTitle != "" {
+			title = lipgloss.NewStyle().Foreground(colError).Bold(true).Render(m.confirmTitle)
+		}
+		ui := lipgloss.JoinVertical(lipgloss.Center,
+			title,
+			"\n",
+			lipgloss.NewStyle().Width(60).Align(lipgloss.Center).Render(m.confirmText),
+			"\n",
+			subTextStyle.Render("y: yes • n/Esc: no"),
+		)
+		return centerContent(boxStyle.Render(ui))
+
+	case StateIDEList:
+		rows := []string{titleStyle.Render(" INSTALLED IDE VERSIONS "), ""}
+		if len(m.ideVersions) == 0 {
+			rows = append(rows, subTextStyle.Render("No PLCnext Engineer found in "+IDEBasePath))
+		}
+		for i, v := range m.ideVersions {
+			label := "v" + v
+			var marks []string
+			if i == 0 {
+				marks = append(marks, "latest")
+			}
+			if v == m.config.DefaultIDEVersion {
+				marks = append(marks, "default")
+			}
+			if m.ideFor.Path != "" && v == m.config.ProjectIDEVersion[m.ideFor.Path] {
+				marks = append(marks, "for "+m.ideFor.Name)
+			}
+			if len(marks) > 0 {
+				label += " (" + strings.Join(marks, ", ") + ")"
+			}
+			if i == m.ideCursor {
+				rows = append(rows, selectedItemStyle.Render(label))
+			} else {
+				rows = append(rows, itemTitleStyle.Render("  "+label))
+			}
+			rows = append(rows, itemDescStyle.Render("  "+m.idePaths[v]))
+		}
+		help := "Enter: start IDE without project • 'd': toggle default • Esc: back"
+		if m.ideFor.Path != "" {
+			help = "Enter: start IDE without project • 'd': toggle default • 'p': use for " + truncate(m.ideFor.Name, 20) + " • Esc: back"
+		}
+		rows = append(rows, "", subTextStyle.Render(help))
+		return centerContent(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+
 	case StateLaunching:
 		info := lipgloss.NewStyle().Foreground(colPrimary).Bold(true).Render(m.selectedPrj.Name)
-		ver := verBadgeStyle.Render("v" + m.selectedPrj.Version)
+		var ver string
+		if m.selectedPrj.Version != "" {
+			ver = verBadgeStyle.Render("v" + m.selectedPrj.Version)
+		}
 
 		branchInfo := ""
 		if m.selectedPrj.GitBranch != "" {
@@ -918,16 +5839,27 @@ func (m model) View() string {
 			if m.config.UseNerdFonts {
 				gitIcon = " "
 			}
-			branchInfo = gitBadgeStyle.Render(gitIcon + m.selectedPrj.GitBranch)
+			branchInfo = gitBadgeStyle.Render(gitIcon + truncate(m.selectedPrj.GitBranch, max(12, m.width/3)))
+		}
+
+		launchHint := "Checking processes..."
+		if m.slowLaunch {
+			launchHint = "IDE is slow to start, this is normal for the first launch..."
+		}
+
+		modeInfo := ""
+		if m.readOnlyLaunch {
+			modeInfo = typeBadgeStyle.Render("READ-ONLY (backup)")
 		}
 
 		ui := lipgloss.JoinVertical(lipgloss.Center,
 			m.spinner.View()+" Launching Environment",
 			"\n",
 			info,
-			lipgloss.JoinHorizontal(lipgloss.Center, ver, branchInfo),
+			lipgloss.JoinHorizontal(lipgloss.Center, ver, branchInfo, modeInfo),
 			"\n",
-			lipgloss.NewStyle().Italic(true).Foreground(colSubText).Render("Checking processes..."),
+			lipgloss.NewStyle().Italic(true).Foreground(colSubText).Render(launchHint),
+			subTextStyle.Render("Esc: stop waiting (IDE keeps starting)"),
 		)
 		return centerContent(boxStyle.Render(ui))
 
@@ -952,19 +5884,458 @@ func (m model) View() string {
 		return centerContent(boxStyle.Render(ui))
 
 	case StateError:
-		ui := lipgloss.JoinVertical(lipgloss.Center,
-			lipgloss.NewStyle().Foreground(colError).Bold(true).Render("✖ ERROR"),
+		lines := m.errorLines()
+		visible := max(3, m.height-14)
+		start := min(m.errScroll, max(0, len(lines)-visible))
+		end := min(len(lines), start+visible)
+		body := strings.Join(lines[start:end], "\n")
+		if len(lines) > visible {
+			body += "\n" + subTextStyle.Render(fmt.Sprintf("(%d-%d of %d lines, ↑/↓ to scroll)", start+1, end, len(lines)))
+		}
+		title := "✖ ERROR"
+		if m.errOp != "" {
+			title += ": " + m.errOp
+		}
+		help := "c: copy • l: open log • any other key: back"
+		if m.errRetry != nil {
+			help = "r: retry • " + help
+		}
+		rows := []string{
+			lipgloss.NewStyle().Foreground(colError).Bold(true).Render(truncate(title, 60)),
 			"\n",
-			lipgloss.NewStyle().Width(50).Align(lipgloss.Center).Render(fmt.Sprintf("%v", m.err)),
+			lipgloss.NewStyle().Width(60).Align(lipgloss.Center).Render(body),
 			"\n",
-			subTextStyle.Render("Press any key to return"),
-		)
-		return centerContent(boxStyle.Render(ui))
+		}
+		if m.statusMsg != "" {
+			rows = append(rows, subTextStyle.Render(m.statusMsg))
+		}
+		rows = append(rows, subTextStyle.Render(help))
+		return centerContent(boxStyle.Render(lipgloss.JoinVertical(lipgloss.Center, rows...)))
 	}
 
 	return ""
 }
 
+// splitPathSegments splits p into components, each keeping its trailing separator.
+func splitPathSegments(p string) []string {
+	var segs []string
+	start := 0
+	for i, r := range p {
+		if r == '\\' || r == '/' {
+			segs = append(segs, p[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(p) {
+		segs = append(segs, p[start:])
+	}
+	return segs
+}
+
+// truncate cuts s to max terminal cells, ending with "..." when shortened.
+func truncate(s string, max int) string {
+	if lipgloss.Width(s) <= max {
+		return s
+	}
+	if max <= 3 {
+		return ansi.Truncate(s, max, "")
+	}
+	return ansi.Truncate(s, max, "...")
+}
+
+// truncateLeft is truncate keeping the end of s ("...\Customer\main").
+// Like truncate it counts terminal cells and never splits a character.
+func truncateLeft(s string, max int) string {
+	w := lipgloss.Width(s)
+	if w <= max {
+		return s
+	}
+	prefix := "..."
+	if max <= 3 {
+		prefix = ""
+	}
+	keep := max - len(prefix)
+	// TruncateLeft keeps a wide character cut in half, drop it as well
+	tail := ansi.TruncateLeft(s, w-keep, "")
+	for cut := w - keep + 1; lipgloss.Width(tail) > keep; cut++ {
+		tail = ansi.TruncateLeft(s, cut, "")
+	}
+	return prefix + tail
+}
+
+// shortenPath fits p into maxLen terminal cells by replacing middle segments with
+// "...", keeping the root and the last one or two segments:
+// C:\Users\me\Projects\Customer\main -> C:\...\Customer\main
+func shortenPath(p string, maxLen int) string {
+	if lipgloss.Width(p) <= maxLen {
+		return p
+	}
+	sep := "\\"
+	if !strings.Contains(p, sep) {
+		sep = "/"
+	}
+	segs := splitPathSegments(p)
+	rootLen := 1
+	if strings.HasPrefix(p, `\\`) && len(segs) > 4 {
+		rootLen = 4 // UNC: \\server\share\
+	}
+	if len(segs) > rootLen+1 {
+		root := strings.Join(segs[:rootLen], "")
+		for keep := 2; keep >= 1; keep-- {
+			if len(segs)-rootLen <= keep {
+				continue
+			}
+			short := root + "..." + sep + strings.Join(segs[len(segs)-keep:], "")
+			if lipgloss.Width(short) <= maxLen {
+				return short
+			}
+		}
+	}
+	return truncateLeft(p, maxLen)
+}
+
+// wrapPath renders p on as many lines as needed to fit width, breaking only
+// between path segments (a single overlong segment is broken hard). The
+// parent folder of the project is highlighted.
+func wrapPath(p string, width int) string {
+	segs := splitPathSegments(p)
+	parent := len(segs) - 2
+	highlight := lipgloss.NewStyle().Foreground(colAccent).Bold(true)
+
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	for i, seg := range segs {
+		for lipgloss.Width(seg) > width && width > 0 {
+			if lineWidth > 0 {
+				lines = append(lines, line.String())
+				line.Reset()
+				lineWidth = 0
+			}
+			head := ansi.Cut(seg, 0, width)
+			if head == "" { // a single character wider than width
+				head = string([]rune(seg)[:1])
+			}
+			lines = append(lines, itemDescStyle.Render(head))
+			seg = strings.TrimPrefix(seg, head)
+		}
+		w := lipgloss.Width(seg)
+		if lineWidth+w > width && lineWidth > 0 {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineWidth = 0
+		}
+		if i == parent {
+			line.WriteString(highlight.Render(seg))
+		} else {
+			line.WriteString(subTextStyle.Render(seg))
+		}
+		lineWidth += w
+	}
+	if lineWidth > 0 {
+		lines = append(lines, line.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// compareView shows the two marked projects in columns, values that differ
+// are highlighted.
+func (m model) compareView() string {
+	colWidth := max(20, min(50, (m.width-24)/2))
+	label := lipgloss.NewStyle().Foreground(colSubText).Width(12)
+	cell := lipgloss.NewStyle().Width(colWidth).PaddingRight(2)
+
+	values := func(p ProjectInfo, f compareFacts) []string {
+		version := p.Version
+		if p.VersionPending {
+			version = "…"
+		}
+		branch := p.GitBranch
+		if branch == "" {
+			branch = "-"
+		}
+		size, modified, firmware := "…", "…", "…"
+		if !m.comparePending {
+			size = humanizeBytes(f.Size)
+			modified = "-"
+			if !f.Modified.IsZero() {
+				modified = f.Modified.Format("2006-01-02 15:04")
+			}
+			firmware = f.Firmware
+			if firmware == "" {
+				firmware = "-"
+			}
+		}
+		launched := "never"
+		if t, ok := m.config.LaunchTimes[p.Path]; ok {
+			launched = t.Format("2006-01-02 15:04")
+		}
+		return []string{p.Name, p.Type.Label(), version, branch, firmware, size, modified, launched, shortenPath(p.Path, colWidth-2)}
+	}
+	names := []string{"Name", "Type", "Version", "Branch", "Target", "Size", "Modified", "Launched", "Path"}
+	left := values(m.compare[0], m.compareFacts[0])
+	right := values(m.compare[1], m.compareFacts[1])
+
+	rows := []string{titleStyle.Render(" COMPARE "), ""}
+	for i, name := range names {
+		a, b := truncate(left[i], colWidth-2), truncate(right[i], colWidth-2)
+		if left[i] != right[i] && name != "Name" && name != "Path" { // always differ
+			a, b = compareDiffStyle.Render(a), compareDiffStyle.Render(b)
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, label.Render(name), cell.Render(a), cell.Render(b)))
+	}
+	rows = append(rows, "", subTextStyle.Render("Highlighted values differ • Esc: back"))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+// detailsView renders the project details panel.
+func (m model) detailsView() string {
+	p := m.selectedPrj
+	width := m.width - 12
+	if width < 20 {
+		width = 20
+	}
+	label := lipgloss.NewStyle().Foreground(colSubText).Width(12)
+	row := func(name, value string) string {
+		return lipgloss.JoinHorizontal(lipgloss.Top, label.Render(name), value)
+	}
+
+	version := p.Version
+	if p.VersionPending {
+		version = "…"
+	}
+	rows := []string{
+		titleStyle.Render(" PROJECT DETAILS "),
+		"",
+		itemTitleStyle.Render(p.Name),
+		"",
+		row("Type", p.Type.Label()),
+		row("Version", version),
+	}
+	for _, v := range p.MixedVersions {
+		rows = append(rows, row("", lipgloss.NewStyle().Foreground(colError).Render(v)))
+	}
+	switch {
+	case m.libsPending:
+		rows = append(rows, row("Libraries", "…"))
+	case len(m.detailsLibs) == 0:
+		rows = append(rows, row("Libraries", subTextStyle.Render("none listed in the metadata")))
+	default:
+		for i, lib := range m.detailsLibs {
+			if i == MaxDetailsLibraries {
+				rows = append(rows, row("", subTextStyle.Render(fmt.Sprintf("… %d more", len(m.detailsLibs)-i))))
+				break
+			}
+			name := ""
+			if i == 0 {
+				name = "Libraries"
+			}
+			rows = append(rows, row(name, truncate(lib, width-12)))
+		}
+	}
+	if p.FlatVersion != "" {
+		rows = append(rows, row("Flat folder", lipgloss.NewStyle().Foreground(colError).Render(
+			fmt.Sprintf("%s (archive is %s, may be outdated)", p.FlatVersion, p.Version))))
+	}
+	help := "Enter: launch • Esc: back"
+	if p.GitBranch != "" {
+		rows = append(rows, row("Branch", truncate(p.GitBranch, width-12)))
+		if p.Git.Submodules > 0 {
+			rows = append(rows, row("Submodules", lipgloss.NewStyle().Foreground(colError).Render(
+				fmt.Sprintf("%d not initialized (offered on launch)", p.Git.Submodules))))
+		}
+		switch {
+		case m.remotePending:
+			rows = append(rows, row("Remote", "…"))
+		case m.detailsRemote == "":
+			rows = append(rows, row("Remote", subTextStyle.Render("none")))
+		default:
+			rows = append(rows, row("Remote", remoteLink(m.detailsRemote, width-12)))
+			help = "Enter: launch • c: copy remote • o: open in browser • Esc: back"
+		}
+	}
+	if t, ok := m.config.LaunchTimes[p.Path]; ok {
+		rows = append(rows, row("Launched", fmt.Sprintf("%s (%s), %d times",
+			humanizeSince(t), t.Format("2006-01-02 15:04"), m.config.LaunchCounts[p.Path])))
+	}
+	if disk := m.diskFreeText(p.Path); disk != "" {
+		rows = append(rows, row("Disk", disk))
+	}
+	rows = append(rows, "", label.Render("Path"), wrapPath(p.Path, width))
+	if m.statusMsg != "" {
+		rows = append(rows, "", subTextStyle.Render(m.statusMsg))
+	}
+	rows = append(rows, "", subTextStyle.Render(help))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
+
+// remoteLink renders url truncated to width; web URLs become OSC 8 hyperlinks
+// in terminals that support them (Windows Terminal does).
+func remoteLink(url string, width int) string {
+	text := truncate(url, width)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return text
+	}
+	return ansi.SetHyperlink(url) + text + ansi.ResetHyperlink()
+}
+
+// ======================================================================================
+// EXPORT
+// ======================================================================================
+
+// Export formats. FormatTable is only offered on the command line.
+const (
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+	FormatTable = "table"
+)
+
+// ExportRecord is one project in an exported list.
+type ExportRecord struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Type      string `json:"type"`
+	Version   string `json:"version"`
+	Branch    string `json:"branch,omitempty"`
+	GitStatus string `json:"git_status,omitempty"`
+}
+
+// gitStatusText describes st in words, e.g. "dirty, ahead 2".
+func gitStatusText(branch string, st GitStatus) string {
+	if branch == "" {
+		return ""
+	}
+	parts := []string{"clean"}
+	if st.Dirty {
+		parts[0] = "dirty"
+	}
+	if st.Ahead > 0 {
+		parts = append(parts, fmt.Sprintf("ahead %d", st.Ahead))
+	}
+	if st.Behind > 0 {
+		parts = append(parts, fmt.Sprintf("behind %d", st.Behind))
+	}
+	if st.Submodules > 0 {
+		parts = append(parts, fmt.Sprintf("%d submodules not initialized", st.Submodules))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func exportRecords(projects []ProjectInfo) []ExportRecord {
+	records := make([]ExportRecord, 0, len(projects))
+	for _, p := range projects {
+		records = append(records, ExportRecord{
+			Name:      p.Name,
+			Path:      p.Path,
+			Type:      p.Type.Label(),
+			Version:   p.Version,
+			Branch:    p.GitBranch,
+			GitStatus: gitStatusText(p.GitBranch, p.Git),
+		})
+	}
+	return records
+}
+
+// writeProjects writes projects to w as CSV, JSON or an aligned table.
+func writeProjects(w io.Writer, projects []ProjectInfo, format string) error {
+	records := exportRecords(projects)
+	switch format {
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTYPE\tVERSION\tBRANCH\tGIT\tPATH")
+		for _, r := range records {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Name, r.Type, r.Version, r.Branch, r.GitStatus, r.Path)
+		}
+		return tw.Flush()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "path", "type", "version", "branch", "git_status"})
+		for _, r := range records {
+			cw.Write([]string{r.Name, r.Path, r.Type, r.Version, r.Branch, r.GitStatus})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// exportProjects writes projects_<timestamp>.<format> next to the executable.
+func exportProjects(projects []ProjectInfo, format string) (string, error) {
+	exePath, _ := os.Executable()
+	path := filepath.Join(filepath.Dir(exePath), "projects_"+time.Now().Format("20060102_150405")+"."+format)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := writeProjects(f, projects, format); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// runScanCLI scans dirs (the configured work dirs when empty) and prints the
+// projects to stdout without starting the TUI. Returns the process exit code.
+func runScanCLI(dirs []string, format string) int {
+	cfg, _ := loadConfig()
+	if len(dirs) == 0 {
+		dirs = cfg.WorkDirs
+	}
+	if len(dirs) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no directory given and no work directories configured")
+		return 1
+	}
+	opts := ScanOptions{FollowSymlinks: cfg.FollowSymlinks}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.scanTimeout())
+	defer cancel()
+	var projects []ProjectInfo
+	failed := false
+	for _, dir := range dirs {
+		found, err := scanDir(ctx, dir, opts)
+		projects = append(projects, found...)
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "Error: scan aborted on timeout, results are incomplete")
+			failed = true
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot scan %s: not an accessible directory\n", dir)
+			failed = true
+		}
+	}
+	projects = dedupeProjects(projects)
+	sortProjects(projects, SortByName, nil)
+	if err := writeProjects(os.Stdout, projects, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+type exportResultMsg struct {
+	path string
+	err  error
+}
+
+func exportCmd(projects []ProjectInfo, format string) tea.Cmd {
+	return func() tea.Msg {
+		path, err := exportProjects(projects, format)
+		if err != nil {
+			WriteLog(fmt.Sprintf("Export error: %v", err))
+		} else {
+			WriteLog(fmt.Sprintf("Exported %d projects to %s", len(projects), path))
+		}
+		return exportResultMsg{path: path, err: err}
+	}
+}
+
 // ======================================================================================
 // LAUNCH COMMANDS
 // ======================================================================================
@@ -972,49 +6343,418 @@ func (m model) View() string {
 type launchResultMsg struct {
 	message string
 	err     error
+	seq     int // matches model.launchSeq unless the wait was cancelled
+}
+
+type slowLaunchMsg struct{ seq int }
+
+func slowLaunchTimer(d time.Duration, seq int) tea.Cmd {
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return slowLaunchMsg{seq: seq}
+	})
+}
+
+// launchWithSeq tags the result of launchProjectCmd with the launch sequence
+// number and records it in the metrics log.
+func launchWithSeq(proj ProjectInfo, cfg Config, seq int, readOnly, keepOtherIDEs bool) tea.Cmd {
+	launch := launchProjectCmd(proj, cfg, readOnly, keepOtherIDEs)
+	return func() tea.Msg {
+		start := time.Now()
+		res := launch().(launchResultMsg)
+		res.seq = seq
+		event := MetricEvent{
+			Time:       start,
+			Event:      "launch",
+			Project:    proj.Name,
+			Path:       proj.Path,
+			Version:    proj.Version,
+			Branch:     proj.GitBranch,
+			ReadOnly:   readOnly,
+			Success:    res.err == nil,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if res.err != nil {
+			event.Error = res.err.Error()
+		}
+		writeMetric(event)
+		return res
+	}
+}
+
+// LaunchEvent is the JSON body posted to Config.WebhookURL after a launch.
+type LaunchEvent struct {
+	Project string    `json:"project"`
+	Path    string    `json:"path"`
+	Version string    `json:"version"`
+	Branch  string    `json:"branch,omitempty"`
+	User    string    `json:"user"`
+	Host    string    `json:"host"`
+	IDE     string    `json:"ide"`
+	Time    time.Time `json:"time"`
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USERNAME")
+}
+
+// sendLaunchWebhook posts a LaunchEvent; failures are only logged.
+func sendLaunchWebhook(url string, proj ProjectInfo, ide string) {
+	host, _ := os.Hostname()
+	body, err := json.Marshal(LaunchEvent{
+		Project: proj.Name,
+		Path:    proj.Path,
+		Version: proj.Version,
+		Branch:  proj.GitBranch,
+		User:    currentUser(),
+		Host:    host,
+		IDE:     ide,
+		Time:    time.Now(),
+	})
+	if err != nil {
+		WriteLog(fmt.Sprintf("Webhook error: %v", err))
+		return
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		WriteLog(fmt.Sprintf("Webhook error: %v", err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		WriteLog(fmt.Sprintf("Webhook rejected: %s", resp.Status))
+		return
+	}
+	WriteLog("Webhook sent: " + resp.Status)
+}
+
+// Launch modes (Config.LaunchMode).
+const (
+	LaunchModeDirect      = "direct"
+	LaunchModeAssociation = "association"
+)
+
+// launchViaAssociation opens a .pcwef/.pcwex with the application Windows
+// associates with it, without looking for the IDE executable.
+func launchViaAssociation(proj ProjectInfo, launchPath string, cfg Config) launchResultMsg {
+	WriteLog("Opening via file association: " + launchPath)
+	// explorer.exe exits with 1 even on success, only a failure to start counts.
+	if err := exec.Command("explorer", launchPath).Start(); err != nil {
+		WriteLog(fmt.Sprintf("Launch error: %v", err))
+		return launchResultMsg{err: err}
+	}
+	if err := runHook("post-launch", cfg.PostLaunchCommand, proj); err != nil {
+		WriteLog(fmt.Sprintf("post-launch command failed: %v", err))
+	}
+	if cfg.WebhookURL != "" {
+		go sendLaunchWebhook(cfg.WebhookURL, proj, "file association")
+	}
+	return launchResultMsg{message: "Opened with the associated application"}
+}
+
+// checkIDEIntegrity verifies that the IDE executable can be read and is not
+// empty, and that its folder holds the IDE's DLLs. Broken installations and
+// files blocked by an antivirus are reported with the path instead of the
+// opaque error of cmd.Start.
+func checkIDEIntegrity(idePath string) error {
+	info, err := os.Stat(idePath)
+	if err != nil {
+		return fmt.Errorf("IDE executable not accessible: %s (%v)", idePath, err)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("IDE executable is empty, reinstall PLCnext Engineer: %s", idePath)
+	}
+	f, err := os.Open(idePath)
+	if err != nil {
+		return fmt.Errorf("IDE executable cannot be read (blocked by antivirus?): %s (%v)", idePath, err)
+	}
+	_, err = f.Read(make([]byte, 2))
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("IDE executable cannot be read (blocked by antivirus?): %s (%v)", idePath, err)
+	}
+	dlls, _ := filepath.Glob(filepath.Join(filepath.Dir(idePath), "*.dll"))
+	if len(dlls) == 0 {
+		return fmt.Errorf("IDE installation looks incomplete, no DLLs next to %s", idePath)
+	}
+	return nil
+}
+
+// errWindowsOnly is returned by the winapi_other.go stubs.
+var errWindowsOnly = errors.New("only supported on Windows")
+
+var errLogonFailed = errors.New("authentication failed: wrong user name or password")
+
+// splitAccount splits "DOMAIN\user" into user and domain. "user@domain"
+// (UPN) and plain local names are returned as the user with no domain.
+func splitAccount(account string) (user, domain string) {
+	if d, u, ok := strings.Cut(account, `\`); ok {
+		if d == "." {
+			d = ""
+		}
+		return u, d
+	}
+	return account, ""
+}
+
+// launchAsUserCmd opens proj in its IDE under another account.
+func launchAsUserCmd(proj ProjectInfo, cfg Config, account, password string, seq int) tea.Cmd {
+	return func() tea.Msg {
+		WriteLog("---------------------------------------------------------------")
+		WriteLog(fmt.Sprintf("Launching %s as user %s", proj.Name, account))
+		if proj.VersionPending {
+			proj.Version = archiveVersion(proj.Path)
+		}
+		idePath, rule, err := resolveIDE(proj, cfg)
+		if err != nil {
+			return launchResultMsg{err: err, seq: seq}
+		}
+		WriteLog(fmt.Sprintf("IDE selection rule: %s -> %s", rule, idePath))
+		launchPath := proj.Path
+		if abs, err := filepath.Abs(launchPath); err == nil {
+			launchPath = abs
+		}
+		args := append(ideLanguageArgs(cfg), launchPath)
+		user, domain := splitAccount(account)
+		if err := launchAsUser(idePath, args, filepath.Dir(idePath), user, domain, password); err != nil {
+			WriteLog(fmt.Sprintf("Launch as %s failed: %v", account, err))
+			return launchResultMsg{err: err, seq: seq}
+		}
+		return launchResultMsg{message: fmt.Sprintf("IDE started as %s: %s", account, filepath.Base(idePath)), seq: seq}
+	}
+}
+
+// focusWithRetry calls focusProcessWindow up to attempts times, waiting delay
+// before each try: the IDE may still be busy taking the new project.
+func focusWithRetry(pid int32, attempts int, delay time.Duration) error {
+	var err error
+	for i := 1; i <= attempts; i++ {
+		time.Sleep(delay)
+		if err = focusProcessWindow(pid); err == nil || errors.Is(err, errWindowsOnly) {
+			return err
+		}
+		WriteLog(fmt.Sprintf("Focus attempt %d/%d failed: %v", i, attempts, err))
+	}
+	return err
+}
+
+// HookTimeout bounds pre/post launch commands.
+const HookTimeout = 60 * time.Second
+
+// expandHook fills the {path}, {version} and {branch} placeholders of a hook command.
+func expandHook(command string, proj ProjectInfo) string {
+	return strings.NewReplacer(
+		"{path}", proj.Path,
+		"{version}", proj.Version,
+		"{branch}", proj.GitBranch,
+	).Replace(command)
+}
+
+// runHook runs a configured launch hook in the project folder and logs its output.
+func runHook(name, command string, proj ProjectInfo) error {
+	if command == "" {
+		return nil
+	}
+	line := expandHook(command, proj)
+	WriteLog(fmt.Sprintf("Running %s command: %s", name, line))
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+	cmd := shellCommand(ctx, line)
+	cmd.Dir = projectDir(proj)
+	out, err := cmd.CombinedOutput()
+	if text := strings.TrimSpace(string(out)); text != "" {
+		WriteLog(name + " output: " + text)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", HookTimeout)
+	}
+	return err
+}
+
+// SessionLaunchDelay gives the IDE time to take a project before the next one.
+const SessionLaunchDelay = 3 * time.Second
+
+// launchSessionCmd launches projects in order. Projects open in a running IDE
+// or with a lock file are skipped instead of being opened twice; skipped
+// counts those left out before. IDEs of other versions are kept running, a
+// session may mix versions.
+func launchSessionCmd(projects []ProjectInfo, cfg Config, seq, skipped int) tea.Cmd {
+	return func() tea.Msg {
+		started := 0
+		var errs []error
+		running := RunningIDEs()
+		for _, proj := range projects {
+			if why, busy := projectInUse(proj, running, cfg.LockFilePatterns); busy {
+				WriteLog(fmt.Sprintf("Session: %s is already open (%s), skipped", proj.Name, why))
+				skipped++
+				continue
+			}
+			if started > 0 {
+				time.Sleep(SessionLaunchDelay)
+			}
+			res := launchWithSeq(proj, cfg, seq, false, true)().(launchResultMsg)
+			if res.err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", proj.Name, res.err))
+				continue
+			}
+			started++
+		}
+		msg := fmt.Sprintf("%d project(s) started, %d already open", started, skipped)
+		WriteLog("Session: " + msg)
+		return launchResultMsg{message: msg, err: errors.Join(errs...), seq: seq}
+	}
+}
+
+// ideLanguageArgs returns extra IDE arguments for Config.IDELanguage.
+//
+// PLCnext Engineer has no documented command line switch for the UI language:
+// it is chosen in the IDE options and stored per Windows user. The launcher does
+// not touch that setting; if a particular IDE build accepts a switch, configure
+// it through IDELanguageArg (e.g. "/language:{lang}").
+func ideLanguageArgs(cfg Config) []string {
+	if cfg.IDELanguage == "" {
+		return nil
+	}
+	if cfg.IDELanguageArg == "" {
+		WriteLog(fmt.Sprintf("IDE language %q configured without ide_language_arg, ignoring", cfg.IDELanguage))
+		return nil
+	}
+	WriteLog("IDE language: " + cfg.IDELanguage)
+	return []string{strings.ReplaceAll(cfg.IDELanguageArg, "{lang}", cfg.IDELanguage)}
+}
+
+// quoteArgs formats arguments the way they would be typed in cmd.exe.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = "\"" + a + "\""
+	}
+	return strings.Join(quoted, " ")
+}
+
+// resolveIDE picks the IDE executable that launchProjectCmd would use for proj.
+func resolveIDE(proj ProjectInfo, cfg Config) (string, string, error) {
+	if proj.VersionPending {
+		proj.Version = archiveVersion(proj.Path)
+	}
+	return selectProjectIDE(proj, cfg, FindInstalledIDEs())
+}
+
+// selectProjectIDE picks the IDE for proj among installed: the version chosen
+// for the project (Config.ProjectIDEVersion), otherwise selectIDE.
+func selectProjectIDE(proj ProjectInfo, cfg Config, installed map[string]string) (string, string, error) {
+	if ver, ok := cfg.ProjectIDEVersion[proj.Path]; ok {
+		if path, ok := installed[ver]; ok {
+			return path, "chosen for this project " + ver, nil
+		}
+		WriteLog(fmt.Sprintf("IDE version %s chosen for %s is not installed, ignoring", ver, proj.Name))
+	}
+	idePath, rule, ok := selectIDE(installed, proj.Version, cfg.DefaultIDEVersion)
+	if !ok {
+		return "", "", fmt.Errorf("no PLCnext Engineer installation found")
+	}
+	return idePath, rule, nil
+}
+
+// launchCommandLine returns the exact command line used to open proj, suitable
+// for a shortcut or a manual run from cmd.exe.
+func launchCommandLine(proj ProjectInfo, cfg Config) (string, error) {
+	idePath, _, err := resolveIDE(proj, cfg)
+	if err != nil {
+		return "", err
+	}
+	launchPath := proj.Path
+	if absPath, err := filepath.Abs(launchPath); err == nil {
+		launchPath = absPath
+	}
+	args := append(ideLanguageArgs(cfg), launchPath)
+	return quoteArgs(append([]string{idePath}, args...)), nil
 }
 
-func launchProjectCmd(proj ProjectInfo) tea.Cmd {
+// launchProjectCmd opens proj in its IDE. Running IDEs of other versions are
+// closed first unless keepOtherIDEs is set (sessions open several versions).
+func launchProjectCmd(proj ProjectInfo, cfg Config, readOnly, keepOtherIDEs bool) tea.Cmd {
 	return func() tea.Msg {
 		WriteLog("---------------------------------------------------------------")
 		WriteLog("Starting launch sequence for: " + proj.Name)
+		// An empty path starts the IDE of proj.Version without opening a project.
+		noProject := proj.Path == ""
+		if noProject {
+			WriteLog("Launch mode: IDE without project")
+		} else if readOnly {
+			// PLCnext Engineer has no read-only switch, so protect the project with a backup instead.
+			WriteLog("Launch mode: read-only (backup before open)")
+			backup, err := backupProject(proj)
+			if err != nil {
+				WriteLog(fmt.Sprintf("Backup error: %v", err))
+				return launchResultMsg{err: fmt.Errorf("backup before read-only open failed: %w", err)}
+			}
+			WriteLog("Backup created: " + backup)
+		} else {
+			WriteLog("Launch mode: normal")
+		}
 
+		if proj.VersionPending {
+			proj.Version = archiveVersion(proj.Path)
+			proj.VersionPending = false
+		}
 		launchPath := proj.Path
 		targetVer := proj.Version
 		WriteLog("Project version detected: " + targetVer)
 
-		absPath, err := filepath.Abs(launchPath)
-		if err == nil {
-			launchPath = absPath
+		if !noProject {
+			if err := runHook("pre-launch", cfg.PreLaunchCommand, proj); err != nil {
+				return launchResultMsg{err: fmt.Errorf("pre-launch command failed: %w", err)}
+			}
 		}
 
-		installed := FindInstalledIDEs()
-		idePath, ok := installed[targetVer]
+		if !noProject {
+			absPath, err := filepath.Abs(launchPath)
+			if err == nil {
+				launchPath = absPath
+			}
+			if lock, found := findProjectLock(proj, cfg.LockFilePatterns); found {
+				WriteLog("Project lock file detected: " + lock)
+			}
+		}
 
-		if !ok {
-			var keys []string
-			for k := range installed {
-				keys = append(keys, k)
-			}
-			sort.Strings(keys)
-			if len(keys) > 0 {
-				idePath = installed[keys[len(keys)-1]]
-				WriteLog(fmt.Sprintf("Exact version %s not found. Using latest available: %s", targetVer, idePath))
+		if !noProject {
+			if kind := driveKind(launchPath); kind != "" {
+				WriteLog("Project drive type: " + kind)
 			} else {
-				return launchResultMsg{err: fmt.Errorf("no PLCnext Engineer installation found")}
+				WriteLog("Project drive type: local")
 			}
-		} else {
-			WriteLog(fmt.Sprintf("Found exact IDE match: %s", idePath))
 		}
 
+		if cfg.LaunchMode == LaunchModeAssociation && !noProject && proj.Type != TypeFlat {
+			WriteLog("Launch mode: association")
+			return launchViaAssociation(proj, launchPath, cfg)
+		}
+		// Flat folders have no file to associate, they always go direct.
+		WriteLog("Launch mode: direct")
+
+		idePath, rule, err := resolveIDE(proj, cfg)
+		if err != nil {
+			return launchResultMsg{err: err}
+		}
+		WriteLog(fmt.Sprintf("IDE selection rule: %s -> %s", rule, idePath))
+		if err := checkIDEIntegrity(idePath); err != nil {
+			WriteLog(fmt.Sprintf("IDE integrity check failed: %v", err))
+			return launchResultMsg{err: err}
+		}
+		WriteLog("IDE integrity check passed")
+
 		// Calculate the intended version from the determined IDE path.
 		// This handles cases where we fallback to a different version or proj.Version was "Unknown"
-		verRe := regexp.MustCompile(`(\d+(\.\d+)+)`)
 		targetDir := filepath.Base(filepath.Dir(idePath))
-		intendedVersion := verRe.FindString(targetDir)
+		intendedVersion := ideVersionRe.FindString(targetDir)
 		WriteLog("Intended IDE version to run: " + intendedVersion)
 
 		// Check ALL running processes to find conflicts
+		var existingPID int32
 		procs, _ := process.Processes()
 		for _, p := range procs {
 			name, err := p.Name()
@@ -1031,9 +6771,11 @@ func launchProjectCmd(proj ProjectInfo) tea.Cmd {
 
 				// Extract version of the running process
 				runningDir := filepath.Base(filepath.Dir(exePath))
-				runningVer := verRe.FindString(runningDir)
+				runningVer := ideVersionRe.FindString(runningDir)
 
-				if runningVer != "" && runningVer != intendedVersion {
+				if runningVer != "" && runningVer != intendedVersion && keepOtherIDEs {
+					WriteLog(fmt.Sprintf("Keeping running IDE v%s (PID: %d) for the session", runningVer, p.Pid))
+				} else if runningVer != "" && runningVer != intendedVersion {
 					WriteLog(fmt.Sprintf("CONFLICT: Found running IDE v%s (PID: %d). Intended is v%s. Killing...", runningVer, p.Pid, intendedVersion))
 					if err := p.Kill(); err != nil {
 						WriteLog(fmt.Sprintf("Warning: Failed to kill process %d: %v", p.Pid, err))
@@ -1044,30 +6786,558 @@ func launchProjectCmd(proj ProjectInfo) tea.Cmd {
 					}
 				} else if runningVer == intendedVersion {
 					WriteLog(fmt.Sprintf("Same version v%s is already running. Proceeding to attach/open.", runningVer))
+					existingPID = p.Pid
 				}
 			}
 		}
 
-		WriteLog(fmt.Sprintf("Executing: %s \"%s\"", idePath, launchPath))
-		cmd := exec.Command(idePath, launchPath)
+		if len(launchPath) >= MaxPath {
+			// The IDE does not handle long paths, hand over the 8.3 form instead.
+			short := shortPathName(launchPath)
+			WriteLog(fmt.Sprintf("Project path exceeds MAX_PATH (%d chars), using short path: %s", len(launchPath), short))
+			launchPath = short
+		}
+		args := ideLanguageArgs(cfg)
+		if !noProject {
+			args = append(args, launchPath)
+		}
+		WriteLog(fmt.Sprintf("Executing: %s %s", idePath, quoteArgs(args)))
+		cmd := exec.Command(idePath, args...)
 		cmd.Dir = filepath.Dir(idePath)
 		if err := cmd.Start(); err != nil {
 			WriteLog(fmt.Sprintf("Launch error: %v", err))
 			return launchResultMsg{err: err}
 		}
 
+		if existingPID != 0 {
+			// The running instance receives the project, bring its window back (also from minimized).
+			if err := focusWithRetry(existingPID, cfg.focusAttempts(), cfg.focusDelay()); err != nil {
+				WriteLog(fmt.Sprintf("Could not focus IDE window: %v", err))
+			} else {
+				WriteLog(fmt.Sprintf("Focused existing IDE window (PID: %d)", existingPID))
+			}
+		}
+
+		if noProject {
+			return launchResultMsg{message: fmt.Sprintf("IDE started: %s", filepath.Base(idePath))}
+		}
+		if err := runHook("post-launch", cfg.PostLaunchCommand, proj); err != nil {
+			WriteLog(fmt.Sprintf("post-launch command failed: %v", err))
+		}
+		if cfg.WebhookURL != "" {
+			go sendLaunchWebhook(cfg.WebhookURL, proj, filepath.Base(idePath))
+		}
+
 		return launchResultMsg{message: fmt.Sprintf("IDE started: %s", filepath.Base(idePath))}
 	}
 }
 
+// ======================================================================================
+// WINDOWS INTEGRATION
+// ======================================================================================
+
+// psQuote escapes a value for a single-quoted PowerShell string.
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// runPowerShell executes a script without loading the user profile.
+func runPowerShell(script string) (string, error) {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+var invalidFileNameChars = regexp.MustCompile(`[<>:"/\\|?*]`)
+
+// openEditorCmd opens the project folder with the configured editor.
+func openEditorCmd(p ProjectInfo, editor string) tea.Cmd {
+	return func() tea.Msg {
+		exe, err := exec.LookPath(editor)
+		if err != nil {
+			WriteLog(fmt.Sprintf("Editor %q not found: %v", editor, err))
+			return statusMsg{text: fmt.Sprintf("Editor %q not found, set editor_command in %s", editor, ConfigFileName)}
+		}
+		dir := projectDir(p)
+		if err := exec.Command(exe, dir).Start(); err != nil {
+			WriteLog(fmt.Sprintf("Editor %s failed: %v", exe, err))
+			return statusMsg{text: "Failed to start editor, see log"}
+		}
+		WriteLog(fmt.Sprintf("Opened %s in %s", dir, exe))
+		return statusMsg{text: "Opened in " + filepath.Base(exe)}
+	}
+}
+
+// openURL opens url in the default browser.
+func openURL(url string) error {
+	// explorer.exe hands URLs to the registered protocol handler; its exit code
+	// is meaningless, so only a failure to start counts.
+	return exec.Command("explorer", url).Start()
+}
+
+// createDesktopShortcut writes <Desktop>\<project>.lnk that starts the launcher
+// with --launch <path>. Returns the shortcut path.
+func createDesktopShortcut(proj ProjectInfo) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	desktop := filepath.Join(os.Getenv("USERPROFILE"), "Desktop")
+	if _, err := os.Stat(desktop); err != nil {
+		return "", fmt.Errorf("desktop folder not found: %s", desktop)
+	}
+	lnk := filepath.Join(desktop, invalidFileNameChars.ReplaceAllString(proj.Name, "_")+".lnk")
+	script := fmt.Sprintf(`$s = (New-Object -ComObject WScript.Shell).CreateShortcut(%s)
+$s.TargetPath = %s
+$s.Arguments = %s
+$s.WorkingDirectory = %s
+$s.IconLocation = %s
+$s.Description = %s
+$s.Save()`,
+		psQuote(lnk),
+		psQuote(exe),
+		psQuote(fmt.Sprintf(`--launch "%s"`, proj.Path)),
+		psQuote(filepath.Dir(exe)),
+		psQuote(exe+",0"),
+		psQuote("Open "+proj.Name+" in PLCnext Engineer"),
+	)
+	if _, err := runPowerShell(script); err != nil {
+		return "", err
+	}
+	return lnk, nil
+}
+
+// showToast displays a Windows toast notification through the WinRT API.
+func showToast(title, body string) error {
+	const appID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+	script := fmt.Sprintf(`[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).AppendChild($t.CreateTextNode(%s)) > $null
+$x.Item(1).AppendChild($t.CreateTextNode(%s)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier(%s).Show([Windows.UI.Notifications.ToastNotification]::new($t))`,
+		psQuote(title), psQuote(body), psQuote(appID))
+	_, err := runPowerShell(script)
+	return err
+}
+
+// notifyCmd rings the terminal bell and shows a toast in the background.
+func notifyCmd(cfg Config, title, body string) tea.Cmd {
+	if !cfg.Notifications {
+		return nil
+	}
+	return func() tea.Msg {
+		fmt.Fprint(os.Stderr, "\a")
+		if err := showToast(title, body); err != nil {
+			WriteLog(fmt.Sprintf("Notification error: %v", err))
+		}
+		return nil
+	}
+}
+
+type folderPickedMsg struct {
+	path string
+	err  error
+}
+
+// pickFolderCmd opens the Windows folder browser dialog.
+func pickFolderCmd(start string) tea.Cmd {
+	return func() tea.Msg {
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms
+$d = New-Object System.Windows.Forms.FolderBrowserDialog
+$d.Description = 'Select the PLCnext projects folder'
+$d.SelectedPath = %s
+if ($d.ShowDialog() -eq [System.Windows.Forms.DialogResult]::OK) { $d.SelectedPath }`, psQuote(start))
+		path, err := runPowerShell(script)
+		if err != nil {
+			WriteLog(fmt.Sprintf("Folder dialog error: %v", err))
+		}
+		return folderPickedMsg{path: path, err: err}
+	}
+}
+
+type shortcutResultMsg struct {
+	path string
+	err  error
+}
+
+func createShortcutCmd(proj ProjectInfo) tea.Cmd {
+	return func() tea.Msg {
+		lnk, err := createDesktopShortcut(proj)
+		if err != nil {
+			WriteLog(fmt.Sprintf("Shortcut error for %s: %v", proj.Path, err))
+		} else {
+			WriteLog("Shortcut created: " + lnk)
+		}
+		return shortcutResultMsg{path: lnk, err: err}
+	}
+}
+
 // ======================================================================================
 // CONFIG UTILS
 // ======================================================================================
 
+// isUNCPath reports whether p is a network path like \\server\share\dir.
+// Device paths (\\?\, \\.\) are not.
+func isUNCPath(p string) bool {
+	p = strings.ReplaceAll(p, "/", `\`)
+	return strings.HasPrefix(p, `\\`) && !strings.HasPrefix(p, `\\?\`) && !strings.HasPrefix(p, `\\.\`)
+}
+
+// uncShare returns the \\server\share root of a UNC path, "" when the server
+// or the share is missing.
+func uncShare(p string) string {
+	parts := strings.FieldsFunc(p, func(r rune) bool { return r == '\\' || r == '/' })
+	if !isUNCPath(p) || len(parts) < 2 {
+		return ""
+	}
+	return `\\` + parts[0] + `\` + parts[1]
+}
+
+// validateWorkDir checks that p can be used as a work dir. UNC paths are used
+// as they are (no drive letter mapping); an unreachable share gets its own
+// message since it usually means a VPN or credentials problem.
+func validateWorkDir(p string) error {
+	if isUNCPath(p) {
+		share := uncShare(p)
+		if share == "" {
+			return errors.New(`network path needs a share: \\server\share\folder`)
+		}
+		if _, err := os.Stat(share + `\`); err != nil {
+			return fmt.Errorf("network share %s is not reachable (connection or credentials?)", share)
+		}
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("directory not found: %s", p)
+	}
+	if err != nil {
+		return err // access denied and the like, the message names the cause
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", p)
+	}
+	return nil
+}
+
+// pathSuggestions lists existing subdirectories completing the typed prefix,
+// e.g. "D:\Pro" -> "D:\Projects\". Works for drive roots and UNC shares.
+func pathSuggestions(value string) []string {
+	if value == "" {
+		return nil
+	}
+	if len(value) == 2 && value[1] == ':' {
+		return []string{value + `\`} // bare drive letter
+	}
+	cut := strings.LastIndexAny(value, `\/`)
+	if cut < 0 {
+		return nil
+	}
+	dir, prefix := value[:cut+1], strings.ToLower(value[cut+1:])
+	if strings.HasPrefix(value, `\\`) && strings.Count(strings.TrimRight(dir, `\/`), `\`) < 3 {
+		return nil // \\server\ itself cannot be listed, only its shares' contents
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var suggestions []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || strings.HasPrefix(e.Name(), "$") {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(e.Name()), prefix) {
+			suggestions = append(suggestions, dir+e.Name()+`\`)
+		}
+	}
+	return suggestions
+}
+
+// ======================================================================================
+// INSTANCE IPC
+// ======================================================================================
+
+// InstanceFileName holds the loopback port and request token of the running
+// launcher, so a second `--launch <path>` hands the project over instead of
+// opening a TUI and a second plain start brings the first one to the front.
+const InstanceFileName = "lazyplcnext.instance"
+
+// InstanceDirName is the folder under %LOCALAPPDATA% holding the instance file.
+const InstanceDirName = "LazyPLCNext"
+
+// releaseInstance frees the single instance mutex, set by main.
+var releaseInstance = func() {}
+
+// IPCTimeout bounds connecting to and talking with the running instance.
+const IPCTimeout = 2 * time.Second
+
+// instanceFilePath is in the per-user %LOCALAPPDATA% (not the shared
+// %TEMP% of a terminal server), other users cannot read the token.
+func instanceFilePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, InstanceDirName, InstanceFileName), nil
+}
+
+// readInstanceFile returns the port and token published by startIPCServer.
+func readInstanceFile() (port, token string, err error) {
+	file, err := instanceFilePath()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", "", err
+	}
+	port, token, ok := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if !ok || port == "" || token == "" {
+		return "", "", fmt.Errorf("malformed instance file %s", file)
+	}
+	return port, token, nil
+}
+
+// ipcLaunchMsg is a project path received from another launcher process.
+type ipcLaunchMsg struct{ path string }
+
+// startIPCServer listens on a loopback port and publishes it together with a
+// random token in the owner-only instance file. Any local process can connect
+// to the port, so requests without the token are rejected. Every received
+// path is passed to deliver.
+func startIPCServer(deliver func(path string)) (net.Listener, error) {
+	file, err := instanceFilePath()
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(secret)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	if err := writeInstanceFile(file, port+" "+token); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // listener closed
+			}
+			go handleIPCConn(conn, token, deliver)
+		}
+	}()
+	WriteLog("Listening for launch requests on 127.0.0.1:" + port)
+	return ln, nil
+}
+
+// writeInstanceFile replaces the instance file with a new one only the
+// current user can read.
+func writeInstanceFile(file, content string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	os.Remove(file) // WriteFile keeps the mode of an existing file
+	return os.WriteFile(file, []byte(content), 0600)
+}
+
+// handleIPCConn answers one "<token> <request>" line, the request being
+// "launch <path>" or "focus".
+func handleIPCConn(conn net.Conn, token string, deliver func(path string)) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(IPCTimeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	got, line, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		WriteLog("Rejected instance request without a valid token from " + conn.RemoteAddr().String())
+		fmt.Fprintln(conn, "error unauthorized")
+		return
+	}
+	if line == "focus" {
+		if err := focusConsoleWindow(); err != nil {
+			WriteLog(fmt.Sprintf("Could not focus launcher window: %v", err))
+		}
+		fmt.Fprintln(conn, "ok")
+		return
+	}
+	path, ok := strings.CutPrefix(line, "launch ")
+	if !ok || path == "" {
+		fmt.Fprintln(conn, "error unknown request")
+		return
+	}
+	WriteLog("Launch request from another instance: " + path)
+	deliver(path)
+	fmt.Fprintln(conn, "ok")
+}
+
+// stopIPCServer closes ln and removes the instance file if it is still ours.
+func stopIPCServer(ln net.Listener) {
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+	if got, _, err := readInstanceFile(); err == nil && got == port {
+		if file, err := instanceFilePath(); err == nil {
+			os.Remove(file)
+		}
+	}
+}
+
+// forwardToRunningInstance hands path to an already running launcher.
+// Returns false when there is none (or it does not answer), the caller then
+// starts normally.
+func forwardToRunningInstance(path string) bool {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return sendIPC("launch " + path)
+}
+
+// sendIPC sends one request line to the running instance and reports
+// whether it was acknowledged.
+func sendIPC(request string) bool {
+	port, token, err := readInstanceFile()
+	if err != nil {
+		return false
+	}
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, IPCTimeout)
+	if err != nil {
+		WriteLog(fmt.Sprintf("Stale instance file, starting normally: %v", err))
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(IPCTimeout))
+	if _, err := fmt.Fprintln(conn, token+" "+request); err != nil {
+		return false
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	return err == nil && strings.TrimSpace(reply) == "ok"
+}
+
 // ======================================================================================
 // CLI UTILS
 // ======================================================================================
 
+// doctorCheck is one line of the --doctor report.
+type doctorCheck struct {
+	name     string
+	ok       bool
+	critical bool   // a failure makes --doctor exit with 1
+	detail   string // what was found
+	advice   string // shown on failure
+}
+
+// runDoctor checks the environment without starting the TUI and prints a
+// report. Returns 1 when a critical check failed, 0 otherwise.
+func runDoctor() int {
+	var checks []doctorCheck
+	cfg, cfgErr := loadConfig()
+	exe, _ := os.Executable()
+	exeDir := filepath.Dir(exe)
+
+	if cfgErr != nil {
+		checks = append(checks, doctorCheck{name: "Config", detail: cfgErr.Error(),
+			advice: "Start LazyPLCNext once and choose a project directory"})
+	} else {
+		checks = append(checks, doctorCheck{name: "Config", ok: true, detail: filepath.Join(exeDir, ConfigFileName)})
+	}
+
+	installed := FindInstalledIDEs()
+	if len(installed) > 0 {
+		checks = append(checks, doctorCheck{name: "PLCnext Engineer", ok: true,
+			detail: strings.Join(sortedIDEVersions(installed), ", ")})
+	} else {
+		checks = append(checks, doctorCheck{name: "PLCnext Engineer", critical: true, detail: "not found in " + IDEBasePath,
+			advice: "Install PLCnext Engineer or check the installation folder"})
+	}
+
+	if len(cfg.WorkDirs) == 0 {
+		checks = append(checks, doctorCheck{name: "Work directories", detail: "none configured",
+			advice: "Press 'c' in the project list to set one"})
+	}
+	missing := 0
+	for _, dir := range cfg.WorkDirs {
+		if err := validateWorkDir(dir); err != nil {
+			missing++
+			checks = append(checks, doctorCheck{name: "Work directory", detail: err.Error(),
+				advice: "Reconnect the drive or change the path"})
+		} else {
+			checks = append(checks, doctorCheck{name: "Work directory", ok: true, detail: dir})
+		}
+	}
+	if len(cfg.WorkDirs) > 0 && missing == len(cfg.WorkDirs) {
+		checks[len(checks)-1].critical = true
+	}
+
+	if ver, err := gitVersion(); err != nil {
+		checks = append(checks, doctorCheck{name: "git", detail: err.Error(),
+			advice: "Install Git for Windows and add it to PATH to see branches and status"})
+	} else {
+		checks = append(checks, doctorCheck{name: "git", ok: true, detail: ver})
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	if resp, err := client.Get("https://api.github.com"); err != nil {
+		checks = append(checks, doctorCheck{name: "GitHub (updates)", detail: err.Error(),
+			advice: "Check the proxy/firewall or set disable_update_check in " + ConfigFileName})
+	} else {
+		resp.Body.Close()
+		checks = append(checks, doctorCheck{name: "GitHub (updates)", ok: true, detail: resp.Status})
+	}
+
+	if f, err := os.CreateTemp(exeDir, ConfigFileName+".*.tmp"); err != nil {
+		checks = append(checks, doctorCheck{name: "Config writable", critical: true, detail: err.Error(),
+			advice: "Move LazyPLCNext to a folder you can write to (not Program Files)"})
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+		checks = append(checks, doctorCheck{name: "Config writable", ok: true, detail: exeDir})
+	}
+
+	if f, err := os.OpenFile(logFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		checks = append(checks, doctorCheck{name: "Log writable", detail: err.Error(),
+			advice: "Check the TEMP environment variable"})
+	} else {
+		f.Close()
+		checks = append(checks, doctorCheck{name: "Log writable", ok: true, detail: logFilePath()})
+	}
+
+	fmt.Printf("LazyPLCNext v%s self-check\n\n", AppVersion)
+	code := 0
+	for _, c := range checks {
+		mark := "✓"
+		if !c.ok {
+			mark = "✗"
+			if c.critical {
+				code = 1
+			}
+		}
+		fmt.Printf("%s %-18s %s\n", mark, c.name, c.detail)
+		if !c.ok && c.advice != "" {
+			fmt.Printf("  → %s\n", c.advice)
+		}
+	}
+	if code != 0 {
+		fmt.Println("\nCritical problems found, LazyPLCNext will not work until they are fixed.")
+	}
+	return code
+}
+
 // buildProjectInfoFromPath constructs a ProjectInfo from a direct file/folder path.
 // Supports .pcwex, .pcwef files and flat project folders (containing Solution.xml).
 func buildProjectInfoFromPath(rawPath string) (ProjectInfo, error) {
@@ -1085,35 +7355,35 @@ func buildProjectInfoFromPath(rawPath string) (ProjectInfo, error) {
 
 	switch {
 	case strings.HasSuffix(lower, ".pcwex"):
-		ver, _ := extractVersionFromZip(absPath)
-		if ver == "" {
-			ver = "Unknown"
-		}
-		branch := getGitBranch(parentDir)
+		ver := archiveVersion(absPath)
+		branch, gitStatus := getGitInfo(parentDir)
 		return ProjectInfo{
-			Name: fileName, Path: absPath, Type: TypePCWEX, Version: ver, GitBranch: branch,
+			Name: pcwexDisplayName(absPath), Path: absPath, Type: TypePCWEX, Version: ver, GitBranch: branch, Git: gitStatus,
 		}, nil
 
 	case strings.HasSuffix(lower, ".pcwef"):
 		baseName := strings.TrimSuffix(filepath.Base(absPath), filepath.Ext(absPath))
 		flatFolder := filepath.Join(parentDir, baseName+"Flat")
 		ver := "Unknown"
+		var mixed []string
 		if _, err := os.Stat(flatFolder); err == nil {
-			ver = extractVersionFromFolder(flatFolder)
+			ver, mixed = extractVersionFromFolder(flatFolder)
 		}
-		branch := getGitBranch(parentDir)
+		branch, gitStatus := getGitInfo(parentDir)
 		return ProjectInfo{
-			Name: fileName, Path: absPath, Type: TypePCWEF, Version: ver, IsPCWEF: true, GitBranch: branch,
+			Name: fileName, Path: absPath, Type: TypePCWEF, Version: ver, IsPCWEF: true, GitBranch: branch, Git: gitStatus,
+			MixedVersions: mixed,
 		}, nil
 
 	default:
 		// Try flat folder (directory containing Solution.xml)
 		if info, err := os.Stat(absPath); err == nil && info.IsDir() {
-			if _, err := os.Stat(filepath.Join(absPath, "Solution.xml")); err == nil {
-				ver := extractVersionFromFolder(absPath)
-				branch := getGitBranch(absPath)
+			if hasSolutionFile(absPath) {
+				ver, mixed := extractVersionFromFolder(absPath)
+				branch, gitStatus := getGitInfo(absPath)
 				return ProjectInfo{
-					Name: filepath.Base(absPath), Path: absPath, Type: TypeFlat, Version: ver, GitBranch: branch,
+					Name: filepath.Base(absPath), Path: absPath, Type: TypeFlat, Version: ver, GitBranch: branch, Git: gitStatus,
+					MixedVersions: mixed,
 				}, nil
 			}
 		}
@@ -1135,29 +7405,67 @@ func loadConfig() (Config, error) {
 	return cfg, err
 }
 
+// saveConfig writes the config to a temporary file next to it and renames it
+// into place, so a failed or interrupted write never leaves a truncated config.
 func saveConfig(cfg Config) error {
 	exePath, _ := os.Executable()
 	configPath := filepath.Join(filepath.Dir(exePath), ConfigFileName)
-	file, err := os.Create(configPath)
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), ConfigFileName+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	encoder := json.NewEncoder(tmp)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(cfg)
+	if err := encoder.Encode(cfg); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(configPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), configPath)
 }
 
 func main() {
+	defer logPanic("main")
 	cleanupOldVersion()
+	cleanupTempExtracts()
 
 	// --- CLI argument handling ---
 	// Usage: LazyPLCNext.exe [path/to/project.pcwef|.pcwex|folder]
+	//        LazyPLCNext.exe --scan [dir...] [--format table|json|csv]
 	//        LazyPLCNext.exe --help
 	var directProj *ProjectInfo
+	noUpdate := false
+	inline := false
+	scan := false
+	var scanDirs []string
+	format := FormatTable
+
+	openProject := func(path string) {
+		proj, err := buildProjectInfoFromPath(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		directProj = &proj
+	}
 
 	args := os.Args[1:]
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch arg {
 		case "-h", "--help", "-help":
 			fmt.Printf("LazyPLCNext v%s\n\n", AppVersion)
@@ -1165,6 +7473,14 @@ func main() {
 			fmt.Println("  LazyPLCNext.exe                          — open project browser")
 			fmt.Println("  LazyPLCNext.exe <path>                   — open project directly")
 			fmt.Println()
+			fmt.Println("Options:")
+			fmt.Println("  --launch <path>                          — open project directly (used by shortcuts)")
+			fmt.Println("  --no-update                              — skip checking GitHub for updates")
+			fmt.Println("  --inline                                 — do not use the alternate screen")
+			fmt.Println("  --scan [dir...]                          — print projects found in dir (default: work dirs) and exit")
+			fmt.Println("  --format table|json|csv                  — output format for --scan (default: table)")
+			fmt.Println("  --doctor                                 — check the environment and print a report")
+			fmt.Println()
 			fmt.Println("Supported project types:")
 			fmt.Println("  *.pcwef   — PLCnext Engineer flat-file project")
 			fmt.Println("  *.pcwex   — PLCnext Engineer zipped project")
@@ -1175,22 +7491,102 @@ func main() {
 			fmt.Println(`  LazyPLCNext.exe "D:\Projects\MyProject\MyProject.pcwex"`)
 			fmt.Println(`  LazyPLCNext.exe "D:\Projects\MyProjectFlat"`)
 			os.Exit(0)
+		case "--doctor":
+			os.Exit(runDoctor())
+		case "--no-update":
+			noUpdate = true
+		case "--inline":
+			inline = true
+		case "--scan":
+			scan = true
+			for i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+				scanDirs = append(scanDirs, args[i])
+			}
+		case "--format":
+			if i+1 >= len(args) {
+				fmt.Println("Error: --format requires json, csv or table")
+				os.Exit(1)
+			}
+			i++
+			format = strings.ToLower(args[i])
+			if format != FormatJSON && format != FormatCSV && format != FormatTable {
+				fmt.Printf("Error: unknown format %q (use json, csv or table)\n", args[i])
+				os.Exit(1)
+			}
+		case "--launch":
+			if i+1 >= len(args) {
+				fmt.Println("Error: --launch requires a project path")
+				os.Exit(1)
+			}
+			i++
+			openProject(args[i])
 		default:
 			// Treat the first non-flag argument as a project path
 			if directProj == nil && !strings.HasPrefix(arg, "-") {
-				proj, err := buildProjectInfoFromPath(arg)
-				if err != nil {
-					fmt.Printf("Error: %v\n", err)
-					os.Exit(1)
-				}
-				directProj = &proj
+				openProject(arg)
 			}
 		}
 	}
 
-	p := tea.NewProgram(initialModel(directProj), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	if scan {
+		os.Exit(runScanCLI(scanDirs, format))
+	}
+
+	if directProj != nil && forwardToRunningInstance(directProj.Path) {
+		fmt.Printf("Opening %s in the running LazyPLCNext\n", directProj.Name)
+		os.Exit(0)
+	}
+
+	// Shortcut launches (direct mode) are short-lived and may run next to the
+	// browser, only the browser itself is single instance.
+	if directProj == nil {
+		release, ok, err := acquireSingleInstance()
+		if err != nil {
+			WriteLog(fmt.Sprintf("Single instance check failed: %v", err))
+		} else if ok {
+			releaseInstance = release
+		} else {
+			WriteLog("Another instance is running, bringing it to the front")
+			allowForeground()
+			sendIPC("focus")
+			fmt.Println("LazyPLCNext is already running.")
+			os.Exit(0)
+		}
+	}
+
+	m := initialModel(directProj, noUpdate)
+	opts := []tea.ProgramOption{tea.WithMouseCellMotion()}
+	if inline || m.config.Inline {
+		m.inline = true
+		WriteLog("Running inline (no alternate screen)")
+	} else {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	p := tea.NewProgram(m, opts...)
+	var ipc net.Listener
+	if !m.directMode {
+		var err error
+		if ipc, err = startIPCServer(func(path string) { p.Send(ipcLaunchMsg{path: path}) }); err != nil {
+			WriteLog(fmt.Sprintf("Launch requests from other instances disabled: %v", err))
+		}
+	}
+	final, err := p.Run()
+	if ipc != nil {
+		stopIPCServer(ipc)
+	}
+	releaseInstance()
+	cleanupTempExtracts()
+	if err != nil {
+		if errors.Is(err, tea.ErrProgramPanic) {
+			// Bubble Tea has already restored the terminal.
+			fmt.Printf("\nLazyPLCNext crashed. The stack trace is in %s, please attach it to a bug report.\n", logFilePath())
+			os.Exit(2)
+		}
 		fmt.Printf("Error: %v", err)
 		os.Exit(1)
 	}
+	if fm, ok := final.(model); ok && fm.restartOnExit {
+		restartApp()
+	}
 }
@@ -0,0 +1,726 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestMain(m *testing.M) {
+	// WriteLog writes to %TEMP%, keep the test runs out of the real log.
+	dir, err := os.MkdirTemp("", "lazyplcnext-test")
+	if err == nil {
+		os.Setenv("TEMP", dir)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// writeFile creates path with its parent folders.
+func writeFile(t testing.TB, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlinkOrSkip(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not available: %v", err)
+	}
+}
+
+func projectNames(projects []ProjectInfo) map[string]int {
+	names := make(map[string]int)
+	for _, p := range projects {
+		names[p.Name]++
+	}
+	return names
+}
+
+func TestScanProjectsFollowsSymlink(t *testing.T) {
+	root := t.TempDir()
+	elsewhere := t.TempDir()
+	writeFile(t, filepath.Join(elsewhere, "Linked", "Solution.xml"), "<Solution/>")
+	symlinkOrSkip(t, elsewhere, filepath.Join(root, "link"))
+
+	projects, err := ScanProjects(context.Background(), root, ScanOptions{FollowSymlinks: true, DeferGit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := projectNames(projects); names["Linked"] != 1 {
+		t.Fatalf("projects behind the link = %v, want Linked once", names)
+	}
+}
+
+func TestScanProjectsSymlinkCycle(t *testing.T) {
+	root := t.TempDir()
+	a, b := filepath.Join(root, "A"), filepath.Join(root, "B")
+	writeFile(t, filepath.Join(a, "ProjA", "Solution.xml"), "<Solution/>")
+	writeFile(t, filepath.Join(b, "ProjB", "Solution.xml"), "<Solution/>")
+	symlinkOrSkip(t, b, filepath.Join(a, "toB"))
+	symlinkOrSkip(t, a, filepath.Join(b, "toA"))
+
+	projects, err := ScanProjects(context.Background(), root, ScanOptions{FollowSymlinks: true, DeferGit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := projectNames(projects)
+	if names["ProjA"] != 1 || names["ProjB"] != 1 || len(projects) != 2 {
+		t.Fatalf("projects = %v, want ProjA and ProjB once each", names)
+	}
+}
+
+func TestFindProjectLockIgnoresOtherProjects(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "Machine [A].pcwex")
+	writeFile(t, archive, "")
+	writeFile(t, filepath.Join(dir, "Other.pcwex.lock"), "")
+	proj := ProjectInfo{Name: "Machine [A]", Path: archive, Type: TypePCWEX}
+
+	if lock, found := findProjectLock(proj, nil); found {
+		t.Fatalf("lock of another project reported: %s", lock)
+	}
+	writeFile(t, archive+".lock", "")
+	if _, found := findProjectLock(proj, nil); !found {
+		t.Fatal("own lock file not found")
+	}
+}
+
+func TestDedupeProjectsOverlappingRoots(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "Customer", "Line1", "Solution.xml"), "<Solution/>")
+	writeFile(t, filepath.Join(root, "Other", "Solution.xml"), "<Solution/>")
+
+	var projects []ProjectInfo
+	for _, dir := range []string{root, filepath.Join(root, "Customer"), root + string(filepath.Separator)} {
+		found, err := ScanProjects(context.Background(), dir, ScanOptions{DeferGit: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		projects = append(projects, found...)
+	}
+	projects = dedupeProjects(projects)
+	names := projectNames(projects)
+	if len(projects) != 2 || names["Line1"] != 1 || names["Other"] != 1 {
+		t.Fatalf("projects = %v, want Line1 and Other once each", names)
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{`C:\Projects\Main`, `c:\projects\main`, true},
+		{`C:\Projects\Main`, `C:/Projects/Main`, true},
+		{`C:\Projects\Main\`, `C:\Projects\Main`, true},
+		{`C:\Projects\.\Main`, `C:\Projects\Main`, true},
+		{`\\server\share\Main`, `//SERVER/share/main`, true},
+		{`C:\Projects\Main`, `C:\Projects\Main2`, false},
+		{`C:\Projects\Main`, `D:\Projects\Main`, false},
+	}
+	for _, tt := range tests {
+		if got := samePath(tt.a, tt.b); got != tt.want {
+			t.Errorf("samePath(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestShortenPath(t *testing.T) {
+	const p = `C:\Users\me\Projects\Customer\main`
+	tests := []struct {
+		maxLen int
+		want   string
+	}{
+		{100, p},
+		{len(p), p},
+		{len(p) - 1, `C:\...\Customer\main`},
+		{20, `C:\...\Customer\main`},
+		{19, `C:\...\main`},
+		{8, `...\main`},
+	}
+	for _, tt := range tests {
+		got := shortenPath(p, tt.maxLen)
+		if got != tt.want {
+			t.Errorf("shortenPath(%d) = %q, want %q", tt.maxLen, got, tt.want)
+		}
+		if len([]rune(got)) > tt.maxLen {
+			t.Errorf("shortenPath(%d) = %q is too long", tt.maxLen, got)
+		}
+	}
+	if got := shortenPath(`\\server\share\a\b\c\project`, 26); got != `\\server\share\...\project` {
+		t.Errorf("UNC root not kept: %q", got)
+	}
+}
+
+// renderItem renders p as the selected row of a list width cells wide.
+func renderItem(d projectDelegate, p ProjectInfo, width int) string {
+	l := newProjectList([]list.Item{p}, d, DefaultActionLaunch)
+	l.SetSize(width, 20)
+	var b strings.Builder
+	d.Render(&b, l, 0, p)
+	return b.String()
+}
+
+func TestTypeIconRender(t *testing.T) {
+	p := ProjectInfo{Name: "Main", Path: `C:\Projects\Main.pcwex`, Type: TypePCWEX, Version: "2024.0"}
+	for _, nerd := range []bool{false, true} {
+		want, other := "📦", "\uf1c6"
+		if nerd {
+			want, other = other, want
+		}
+		if got := TypePCWEX.Icon(nerd); got != want {
+			t.Errorf("Icon(%v) = %q, want %q", nerd, got, want)
+		}
+		out := renderItem(projectDelegate{UseNerdFonts: nerd}, p, 120)
+		if !strings.Contains(out, want) || strings.Contains(out, other) {
+			t.Errorf("nerd fonts %v: row %q should show %q only", nerd, out, want)
+		}
+	}
+}
+
+func TestBranchBadgeGlyph(t *testing.T) {
+	p := ProjectInfo{Name: "Main", Path: `C:\Projects\Main`, Type: TypeFlat, GitBranch: "main"}
+	if out := renderItem(projectDelegate{UseNerdFonts: true}, p, 120); !strings.Contains(out, "\ue0a0 main") {
+		t.Errorf("branch badge without the Nerd Font glyph: %q", out)
+	}
+	if out := renderItem(projectDelegate{}, p, 120); strings.Contains(out, "\ue0a0") {
+		t.Errorf("branch glyph shown without Nerd Fonts: %q", out)
+	}
+}
+
+func TestListItemAtMatchesRender(t *testing.T) {
+	var items []list.Item
+	for _, name := range []string{"Alpha", "Bravo", "Charlie"} {
+		items = append(items, ProjectInfo{Name: name, Path: `C:\Projects\` + name, Type: TypeFlat})
+	}
+	for _, inline := range []bool{false, true} {
+		m := model{state: StateList, width: 100, height: 40, inline: inline, marked: map[string]bool{}}
+		m.list = newProjectList(items, m.delegate(), DefaultActionLaunch)
+		m.list.SetSize(m.width-4, m.height-4)
+		view := strings.Split(m.View(), "\n")
+		if inline {
+			// Inline output sits at the bottom of the terminal.
+			view = append(make([]string, m.height-len(view)), view...)
+		}
+		for row, line := range view {
+			for i, it := range items {
+				if !strings.Contains(line, it.(ProjectInfo).Name) {
+					continue
+				}
+				if got, ok := m.listItemAt(row); !ok || got != i {
+					t.Errorf("inline %v: row %d shows %s, listItemAt = %d, %v", inline, row, it.(ProjectInfo).Name, got, ok)
+				}
+			}
+		}
+	}
+}
+
+// gitRepo creates a repository with one commit on main and a branch "other".
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "file.txt"), "base\n")
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"add", "file.txt"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "-m", "file"},
+		{"branch", "other"},
+	} {
+		if out, err := runGit(dir, args...); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestSwitchBranchCleanTreeKeepsOlderStash(t *testing.T) {
+	dir := gitRepo(t)
+	writeFile(t, filepath.Join(dir, "file.txt"), "older change\n")
+	if _, err := runGit(dir, "-c", "user.name=t", "-c", "user.email=t@t", "stash", "push", "-m", "older"); err != nil {
+		t.Fatal(err)
+	}
+	older := stashRef(dir)
+
+	t.Setenv("LC_ALL", "de_DE.UTF-8") // the result must not depend on git's language
+	if _, err := switchBranch(dir, "other", StashPop); err != nil {
+		t.Fatal(err)
+	}
+	if got := stashRef(dir); got != older {
+		t.Fatalf("older stash was popped: stash ref %q, want %q", got, older)
+	}
+}
+
+func TestSwitchBranchRestoresChanges(t *testing.T) {
+	dir := gitRepo(t)
+	writeFile(t, filepath.Join(dir, "file.txt"), "work in progress\n")
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(v, "t")
+	}
+	if _, err := switchBranch(dir, "other", StashPop); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "file.txt"))
+	if string(data) != "work in progress\n" || stashRef(dir) != "" {
+		t.Fatalf("changes not restored: file %q, stash %q", data, stashRef(dir))
+	}
+}
+
+func TestScanProjectsSolutionFileCase(t *testing.T) {
+	root := t.TempDir()
+	for name, file := range map[string]string{"Upper": "Solution.xml", "Lower": "solution.xml", "Shout": "SOLUTION.XML"} {
+		writeFile(t, filepath.Join(root, name, file), "<Solution/>")
+	}
+	projects, err := ScanProjects(context.Background(), root, ScanOptions{DeferGit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := projectNames(projects); len(names) != 3 {
+		t.Fatalf("projects = %v, want Upper, Lower and Shout", names)
+	}
+}
+
+func BenchmarkScanProjects(b *testing.B) {
+	root := b.TempDir()
+	for c := 0; c < 20; c++ {
+		for p := 0; p < 10; p++ {
+			dir := filepath.Join(root, fmt.Sprintf("Customer%02d", c), fmt.Sprintf("Project%02d", p))
+			writeFile(b, filepath.Join(dir, "Solution.xml"), "<Solution/>")
+			for s := 0; s < 5; s++ {
+				writeFile(b, filepath.Join(dir, "Sources", fmt.Sprintf("Unit%d.st", s)), "")
+			}
+		}
+		writeFile(b, filepath.Join(root, fmt.Sprintf("Customer%02d", c), "Backup.pcwex"), "")
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ScanProjects(context.Background(), root, ScanOptions{DeferGit: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFindVersionInXMLEncodings(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="%s"?><Properties><Property Key="ProductVersion" Value="2024.0.1 LTS" /></Properties>`
+	encode := func(s string, order binary.AppendByteOrder, bom []byte) []byte {
+		out := append([]byte(nil), bom...)
+		for _, u := range utf16.Encode([]rune(s)) {
+			out = order.AppendUint16(out, u)
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"utf-8", []byte(fmt.Sprintf(doc, "utf-8"))},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, fmt.Sprintf(doc, "utf-8")...)},
+		{"utf-16le bom", encode(fmt.Sprintf(doc, "utf-16"), binary.LittleEndian, []byte{0xFF, 0xFE})},
+		{"utf-16be bom", encode(fmt.Sprintf(doc, "utf-16"), binary.BigEndian, []byte{0xFE, 0xFF})},
+		{"windows-1251", []byte(fmt.Sprintf(doc, "windows-1251"))},
+	}
+	for _, tt := range tests {
+		got := findVersionInXML(bytes.NewReader(normalizeXML(tt.content)))
+		if got != "2024.0.1 LTS" {
+			t.Errorf("%s: version = %q", tt.name, got)
+		}
+	}
+}
+
+func TestExtractVersionFromZipPrefersProperties(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "Main.pcwex")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, ver := range map[string]string{
+		"Backup/additional.xml":      "2021.0",
+		"additional.xml":             "2022.0",
+		"_properties/additional.xml": "2024.0",
+		"Old/_properties/README.txt": "",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(w, `<Properties><Property Key="ProductVersion" Value="%s" /></Properties>`, ver)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	ver, err := extractVersionFromZip(archive)
+	if err != nil || ver != "2024.0" {
+		t.Fatalf("version = %q, %v, want the _properties one 2024.0", ver, err)
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	now := time.Now()
+	times := map[string]time.Time{
+		"new":    now,
+		"middle": now.Add(-time.Hour),
+		"old":    now.Add(-48 * time.Hour),
+	}
+	counts := map[string]int{"new": 1, "middle": 5, "old": 9, "untimed": 20}
+
+	if trimHistory(times, counts, 4) {
+		t.Fatal("history within the limit was trimmed")
+	}
+	if !trimHistory(times, counts, 2) {
+		t.Fatal("history over the limit was not trimmed")
+	}
+	want := []string{"new", "middle"}
+	if len(times) != 2 || len(counts) != 2 {
+		t.Fatalf("times %v, counts %v, want only %v", times, counts, want)
+	}
+	for _, p := range want {
+		if _, ok := times[p]; !ok || counts[p] == 0 {
+			t.Errorf("%s dropped: times %v, counts %v", p, times, counts)
+		}
+	}
+}
+
+func TestIPCRequiresToken(t *testing.T) {
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	delivered := make(chan string, 2)
+	ln, err := startIPCServer(func(path string) { delivered <- path })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopIPCServer(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(conn, "launch /evil")
+	reply, _ := bufio.NewReader(conn).ReadString('\n')
+	conn.Close()
+	if strings.TrimSpace(reply) == "ok" {
+		t.Fatal("request without token accepted")
+	}
+	if !sendIPC("launch /project") {
+		t.Fatal("request with token rejected")
+	}
+	if got := <-delivered; got != "/project" {
+		t.Fatalf("delivered %q, want /project", got)
+	}
+	select {
+	case got := <-delivered:
+		t.Fatalf("unauthorized path delivered: %q", got)
+	default:
+	}
+}
+
+func TestFindLibrariesInXML(t *testing.T) {
+	const doc = `<Solution>
+  <Libraries>
+    <Library Name="PLCnextBase" Version="3.1.0" />
+    <LibraryReference Name="Pn_Lib" Version="1.2" />
+    <Library Name="PLCnextBase" Version="3.1.0" />
+    <Library Version="9.9" />
+  </Libraries>
+  <Properties>
+    <Property Key="ControllerFirmware" Value="2024.0.3" />
+    <Property Key="ProductVersion" Value="2024.0" />
+  </Properties>
+</Solution>`
+	got := findLibrariesInXML(strings.NewReader(doc))
+	want := []string{"PLCnextBase 3.1.0", "Pn_Lib 1.2", "ControllerFirmware 2024.0.3"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("libraries = %q, want %q", got, want)
+	}
+}
+
+func TestCleanTargets(t *testing.T) {
+	dir := gitRepo(t)
+	writeFile(t, filepath.Join(dir, "bin", "out.dll"), "build")
+	writeFile(t, filepath.Join(dir, "Sources", "bin", "keep.st"), "nested")
+	writeFile(t, filepath.Join(dir, "OBJ", "tracked.txt"), "source")
+	if out, err := runGit(dir, "add", "OBJ"); err != nil {
+		t.Fatalf("git add: %v %s", err, out)
+	}
+
+	dirs, size := cleanTargets(dir, nil)
+	if len(dirs) != 1 || dirs[0] != filepath.Join(dir, "bin") || size != 5 {
+		t.Fatalf("cleanTargets = %q, %d, want only the top-level bin with 5 bytes", dirs, size)
+	}
+}
+
+func TestPathTruncationWideRunes(t *testing.T) {
+	paths := []string{
+		`C:\Проекты\Заказчик\Линия_сборки\основной`,
+		`D:\プロジェクト\顧客\組立ライン\メイン`,
+		`\\сервер\общая\工程\проект`,
+	}
+	check := func(name, out string, max int) {
+		t.Helper()
+		if !utf8.ValidString(out) {
+			t.Errorf("%s: %q is not valid UTF-8", name, out)
+		}
+		if w := ansi.StringWidth(out); w > max {
+			t.Errorf("%s: %q is %d cells wide, max %d", name, out, w, max)
+		}
+	}
+	for _, p := range paths {
+		for max := 1; max <= ansi.StringWidth(p)+1; max++ {
+			check(fmt.Sprintf("truncateLeft(%q, %d)", p, max), truncateLeft(p, max), max)
+			check(fmt.Sprintf("shortenPath(%q, %d)", p, max), shortenPath(p, max), max)
+		}
+		for width := 2; width <= 20; width++ {
+			for _, line := range strings.Split(wrapPath(p, width), "\n") {
+				check(fmt.Sprintf("wrapPath(%q, %d)", p, width), ansi.Strip(line), width)
+			}
+		}
+	}
+}
+
+func TestTruncateCyrillicBranch(t *testing.T) {
+	const branch = "feature/релиз-2024"
+	for max := 1; max <= ansi.StringWidth(branch); max++ {
+		got := truncate(branch, max)
+		if !utf8.ValidString(got) || ansi.StringWidth(got) > max {
+			t.Errorf("truncate(%q, %d) = %q, %d cells", branch, max, got, ansi.StringWidth(got))
+		}
+	}
+
+	long := branch + "-исправление-ошибок-загрузки"
+	m := model{width: 60, comparePending: true}
+	m.compare[0] = ProjectInfo{Name: "A", Path: `C:\A`, GitBranch: long}
+	m.compare[1] = ProjectInfo{Name: "B", Path: `C:\B`, GitBranch: "main"}
+	for _, line := range strings.Split(m.compareView(), "\n") {
+		if !utf8.ValidString(line) || ansi.StringWidth(line) > m.width {
+			t.Errorf("compare view line %q is wider than %d cells", line, m.width)
+		}
+	}
+}
+
+func TestScanDoneDuringUpdatePrompt(t *testing.T) {
+	var m tea.Model = model{state: StateLoading, width: 100, height: 40, marked: map[string]bool{}}
+	m, _ = m.Update(updateCheckMsg{version: "v9.9.9", url: "https://example.invalid/x"})
+	if got := m.(model).state; got != StateUpdateFound {
+		t.Fatalf("state after update check = %v, want StateUpdateFound", got)
+	}
+	projects := []ProjectInfo{{Name: "Main", Path: `C:\Projects\Main`, Type: TypeFlat}}
+	m, _ = m.Update(scanDoneMsg{scanResult{projects: projects, at: time.Now()}})
+	mm := m.(model)
+	if mm.state != StateUpdateFound || !mm.listReady || len(mm.list.Items()) != 1 {
+		t.Fatalf("scan result lost behind the update prompt: state %v, list ready %v, %d items", mm.state, mm.listReady, len(mm.list.Items()))
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if mm := m.(model); mm.state != StateList || len(mm.list.Items()) != 1 {
+		t.Fatalf("after declining the update: state %v, %d items", mm.state, len(mm.list.Items()))
+	}
+}
+
+func TestUNCPath(t *testing.T) {
+	tests := []struct {
+		path  string
+		unc   bool
+		share string
+	}{
+		{`\\server`, true, ""},
+		{`\\server\share`, true, `\\server\share`},
+		{`\\server\share\dir`, true, `\\server\share`},
+		{`//server/share/dir`, true, `\\server\share`},
+		{`\\?\C:\`, false, ""},
+		{`\\.\pipe\name`, false, ""},
+		{`C:\Projects`, false, ""},
+	}
+	for _, tt := range tests {
+		if got := isUNCPath(tt.path); got != tt.unc {
+			t.Errorf("isUNCPath(%q) = %v, want %v", tt.path, got, tt.unc)
+		}
+		if got := uncShare(tt.path); got != tt.share {
+			t.Errorf("uncShare(%q) = %q, want %q", tt.path, got, tt.share)
+		}
+	}
+}
+
+func TestValidateWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	writeFile(t, file, "")
+	if err := validateWorkDir(dir); err != nil {
+		t.Errorf("existing dir rejected: %v", err)
+	}
+	if err := validateWorkDir(filepath.Join(dir, "missing")); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing dir: %v", err)
+	}
+	if err := validateWorkDir(file); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("file: %v", err)
+	}
+	if err := validateWorkDir(dir + "\x00"); err == nil || strings.Contains(err.Error(), "not found") {
+		t.Errorf("invalid path reported as missing: %v", err)
+	}
+}
+
+func TestEnterHelpFollowsConfig(t *testing.T) {
+	m := model{state: StateList, width: 100, height: 40, marked: map[string]bool{}, listReady: true}
+	m.list = newProjectList(nil, m.delegate(), DefaultActionLaunch)
+	m.setConfig(Config{DefaultAction: DefaultActionDetails})
+	for _, b := range m.list.AdditionalFullHelpKeys() {
+		if b.Help().Key == "enter" && b.Help().Desc != DefaultActionDetails {
+			t.Fatalf("enter help = %q after the config changed, want %q", b.Help().Desc, DefaultActionDetails)
+		}
+	}
+}
+
+func TestDirectLaunchBlocksNewerProject(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "Main.pcwex")
+	writeFile(t, archive, "")
+	var m tea.Model = model{
+		state:       StateLaunching,
+		directMode:  true,
+		selectedPrj: ProjectInfo{Name: "Main", Path: archive, Type: TypePCWEX, VersionPending: true},
+		marked:      map[string]bool{},
+	}
+	m, cmd := m.Update(directLaunchMsg{installed: map[string]string{"2023.0": `C:\IDE\PLCNENG64.exe`}})
+	if cmd == nil {
+		t.Fatal("pending version not read in a command")
+	}
+	// What projectVersionCmd returns for an archive saved by a newer IDE.
+	p := m.(model).selectedPrj
+	p.Version, p.VersionPending = "2025.0", false
+	next := func(m *model, proj ProjectInfo) tea.Cmd { return m.startLaunch(proj, false) }
+	m, _ = m.Update(projectVersionMsg{proj: p, next: next})
+	if mm := m.(model); mm.state != StateConfirm || !strings.Contains(mm.confirmTitle, "NEWER") {
+		t.Fatalf("state %v, title %q, want the newer project confirmation", mm.state, mm.confirmTitle)
+	}
+}
+
+func TestAutoUpdateRestartsAfterQuit(t *testing.T) {
+	var m tea.Model = model{state: StateList, autoUpdating: true, updateVer: "v9.9.9", marked: map[string]bool{}}
+	m, cmd := m.Update(updateDoneMsg{})
+	if !m.(model).restartOnExit || cmd == nil {
+		t.Fatalf("restart not deferred to main: restartOnExit %v, cmd %v", m.(model).restartOnExit, cmd)
+	}
+}
+
+func TestScanReportsMissingHistory(t *testing.T) {
+	root := t.TempDir()
+	kept := filepath.Join(root, "Kept")
+	writeFile(t, filepath.Join(kept, "Solution.xml"), "<Solution/>")
+	gone := filepath.Join(root, "Gone")
+	cfg := Config{
+		WorkDirs:     []string{root},
+		LaunchTimes:  map[string]time.Time{kept: time.Now(), gone: time.Now()},
+		LaunchCounts: map[string]int{gone: 3},
+	}
+	r := scanCmd(cfg)().(scanDoneMsg)
+	if len(r.missing) != 1 || r.missing[0] != gone {
+		t.Fatalf("missing = %q, want only %s", r.missing, gone)
+	}
+	if len(cfg.LaunchTimes) != 2 {
+		t.Fatal("scan changed the model's history")
+	}
+}
+
+func TestPreviewLoadsInBackground(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "Main.pcwex")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	if _, err := zw.Create("Inside/Solution.xml"); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	f.Close()
+
+	p := ProjectInfo{Name: "Main", Path: archive, Type: TypePCWEX}
+	m := model{state: StateList, width: 100, height: 40, marked: map[string]bool{}, listReady: true}
+	m.list = newProjectList([]list.Item{p}, m.delegate(), DefaultActionLaunch)
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	if mm := next.(model); mm.state != StatePreview || !mm.previewPending || cmd == nil {
+		t.Fatalf("state %v, pending %v: preview not loading in the background", mm.state, mm.previewPending)
+	}
+	next, _ = next.Update(cmd())
+	if mm := next.(model); mm.previewPending || !strings.Contains(mm.preview.View(), "Solution.xml") {
+		t.Fatalf("listing not shown: %q", mm.preview.View())
+	}
+}
+
+func TestConfirmLaunchArmsSessionsAndRepeat(t *testing.T) {
+	m := model{
+		state:        StateSessions,
+		config:       Config{ConfirmLaunch: true, Sessions: map[string][]string{"Line": {`C:\Projects\Main`}}},
+		sessionNames: []string{"Line"},
+		marked:       map[string]bool{},
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	mm := next.(model)
+	if mm.state != StateSessions || mm.armedPath == "" {
+		t.Fatalf("first Enter opened the session: state %v, armed %q", mm.state, mm.armedPath)
+	}
+
+	mm = model{state: StateList, config: Config{ConfirmLaunch: true}, marked: map[string]bool{}, listReady: true}
+	mm.list = newProjectList(nil, mm.delegate(), DefaultActionLaunch)
+	target := ProjectInfo{Name: "Main", Path: `C:\Projects\Main`}
+	ran := false
+	mm.rememberLaunch("launch Main", target, func(*model) tea.Cmd { ran = true; return nil })
+	next, _ = mm.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(".")})
+	if ran || next.(model).armedPath != target.Path {
+		t.Fatal("first . repeated the launch without arming")
+	}
+	next.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(".")})
+	if !ran {
+		t.Fatal("second . did not repeat the launch")
+	}
+}
+
+func TestMouseWheelSkipsGroupHeaders(t *testing.T) {
+	items := []list.Item{
+		groupHeader{Title: "v2024.0", Count: 1},
+		ProjectInfo{Name: "Alpha", Path: `C:\Projects\Alpha`, Type: TypeFlat},
+		groupHeader{Title: "v2023.0", Count: 1},
+		ProjectInfo{Name: "Bravo", Path: `C:\Projects\Bravo`, Type: TypeFlat},
+	}
+	var m tea.Model = model{state: StateList, width: 100, height: 40, marked: map[string]bool{}, listReady: true}
+	mm := m.(model)
+	mm.list = newProjectList(items, mm.delegate(), DefaultActionLaunch)
+	mm.list.SetSize(96, 36)
+	mm.list.Select(1)
+	m = mm
+	for _, button := range []tea.MouseButton{tea.MouseButtonWheelDown, tea.MouseButtonWheelUp} {
+		m, _ = m.Update(tea.MouseMsg{Action: tea.MouseActionPress, Button: button})
+		if _, ok := m.(model).list.SelectedItem().(ProjectInfo); !ok {
+			t.Fatalf("wheel %v left the cursor on a group header", button)
+		}
+	}
+	if got := m.(model).list.Index(); got != 1 {
+		t.Fatalf("index after down and up = %d, want 1", got)
+	}
+}
@@ -0,0 +1,44 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os/exec"
+)
+
+func focusProcessWindow(pid int32) error {
+	return errWindowsOnly
+}
+
+func shortPathName(p string) string {
+	return p
+}
+
+func diskFree(path string) (uint64, error) {
+	return 0, errWindowsOnly
+}
+
+// shellCommand runs line through sh, the counterpart of cmd.exe /C.
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// acquireSingleInstance always succeeds, single instance is Windows only.
+func acquireSingleInstance() (release func(), ok bool, err error) {
+	return func() {}, true, nil
+}
+
+func allowForeground() {}
+
+func focusConsoleWindow() error {
+	return errWindowsOnly
+}
+
+func volumeKind(root string) string {
+	return ""
+}
+
+func launchAsUser(exe string, args []string, dir, user, domain, password string) error {
+	return errWindowsOnly
+}
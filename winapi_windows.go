@@ -0,0 +1,256 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// ======================================================================================
+// WINAPI (Windows only, see winapi_other.go for stubs)
+// ======================================================================================
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procCreateProcessWithLogonW = advapi32.NewProc("CreateProcessWithLogonW")
+
+	procGetShortPathNameW   = kernel32.NewProc("GetShortPathNameW")
+	procGetDiskFreeSpaceExW = kernel32.NewProc("GetDiskFreeSpaceExW")
+	procCreateMutexW        = kernel32.NewProc("CreateMutexW")
+	procGetConsoleWindow    = kernel32.NewProc("GetConsoleWindow")
+	procGetDriveTypeW       = kernel32.NewProc("GetDriveTypeW")
+
+	procEnumWindows              = user32.NewProc("EnumWindows")
+	procGetWindowThreadProcessId = user32.NewProc("GetWindowThreadProcessId")
+	procIsWindowVisible          = user32.NewProc("IsWindowVisible")
+	procGetWindow                = user32.NewProc("GetWindow")
+	procIsIconic                 = user32.NewProc("IsIconic")
+	procShowWindow               = user32.NewProc("ShowWindow")
+	procSetForegroundWindow      = user32.NewProc("SetForegroundWindow")
+	procAllowSetForegroundWindow = user32.NewProc("AllowSetForegroundWindow")
+)
+
+const (
+	swRestore = 9
+	gwOwner   = 4
+
+	errorAlreadyExists = 183
+
+	driveRemovable = 2
+	driveRemote    = 4
+	driveCDROM     = 5
+
+	logonWithProfile  = 1
+	errorLogonFailure = 1326
+)
+
+// syscall.NewCallback slots are limited, so one callback is shared and
+// guarded by enumMu.
+var (
+	enumMu       sync.Mutex
+	enumOnce     sync.Once
+	enumCallback uintptr
+	enumPID      uint32
+	enumFound    uintptr
+)
+
+// findMainWindow returns the first visible, unowned top-level window of pid.
+func findMainWindow(pid int32) uintptr {
+	enumOnce.Do(func() {
+		enumCallback = syscall.NewCallback(func(hwnd, _ uintptr) uintptr {
+			var wpid uint32
+			procGetWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&wpid)))
+			if wpid != enumPID {
+				return 1
+			}
+			if visible, _, _ := procIsWindowVisible.Call(hwnd); visible == 0 {
+				return 1
+			}
+			if owner, _, _ := procGetWindow.Call(hwnd, gwOwner); owner != 0 {
+				return 1
+			}
+			enumFound = hwnd
+			return 0 // stop enumeration
+		})
+	})
+
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	enumPID = uint32(pid)
+	enumFound = 0
+	procEnumWindows.Call(enumCallback, 0)
+	return enumFound
+}
+
+// focusProcessWindow restores the main window of pid if minimized and brings it
+// to the foreground (works across monitors, the window keeps its position).
+func focusProcessWindow(pid int32) error {
+	hwnd := findMainWindow(pid)
+	if hwnd == 0 {
+		return fmt.Errorf("process %d has no visible window", pid)
+	}
+	if iconic, _, _ := procIsIconic.Call(hwnd); iconic != 0 {
+		procShowWindow.Call(hwnd, swRestore)
+	}
+	if ok, _, _ := procSetForegroundWindow.Call(hwnd); ok == 0 {
+		return fmt.Errorf("SetForegroundWindow failed for process %d", pid)
+	}
+	return nil
+}
+
+// shortPathName converts p to its 8.3 form so programs without long path
+// support can open it. Returns p unchanged if no short name is available.
+func shortPathName(p string) string {
+	long := p
+	if !strings.HasPrefix(long, `\\?\`) {
+		if strings.HasPrefix(long, `\\`) {
+			long = `\\?\UNC\` + long[2:]
+		} else {
+			long = `\\?\` + long
+		}
+	}
+	src, err := syscall.UTF16PtrFromString(long)
+	if err != nil {
+		return p
+	}
+	buf := make([]uint16, 1024)
+	n, _, _ := procGetShortPathNameW.Call(uintptr(unsafe.Pointer(src)), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if n == 0 || int(n) > len(buf) {
+		return p
+	}
+	short := syscall.UTF16ToString(buf[:n])
+	if strings.HasPrefix(short, `\\?\UNC\`) {
+		return `\\` + short[len(`\\?\UNC\`):]
+	}
+	return strings.TrimPrefix(short, `\\?\`)
+}
+
+// shellCommand runs line through cmd.exe /C. The command line is passed
+// verbatim so quoting typed by the user survives Go's argument escaping.
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd.exe")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd.exe /S /C "` + line + `"`}
+	return cmd
+}
+
+// diskFree returns the bytes available to the current user on the volume
+// holding path (GetDiskFreeSpaceEx honours quotas).
+func diskFree(path string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var avail, total, free uint64
+	ok, _, callErr := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&avail)), uintptr(unsafe.Pointer(&total)), uintptr(unsafe.Pointer(&free)))
+	if ok == 0 {
+		return 0, fmt.Errorf("GetDiskFreeSpaceEx %s: %w", path, callErr)
+	}
+	return avail, nil
+}
+
+// acquireSingleInstance takes the launcher's named mutex. ok is false when
+// another instance already holds it; release frees the mutex on exit.
+func acquireSingleInstance() (release func(), ok bool, err error) {
+	name, err := syscall.UTF16PtrFromString(`Local\LazyPLCNext`)
+	if err != nil {
+		return nil, false, err
+	}
+	h, _, callErr := procCreateMutexW.Call(0, 0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, false, fmt.Errorf("CreateMutex: %w", callErr)
+	}
+	if errno, isErrno := callErr.(syscall.Errno); isErrno && errno == errorAlreadyExists {
+		syscall.CloseHandle(syscall.Handle(h))
+		return nil, false, nil
+	}
+	var once sync.Once
+	return func() { once.Do(func() { syscall.CloseHandle(syscall.Handle(h)) }) }, true, nil
+}
+
+// asfwAny (ASFW_ANY) allows any process to set the foreground window.
+const asfwAny = ^uintptr(0)
+
+// allowForeground lets the running instance take the foreground, which
+// Windows only grants to the process that received the last input.
+func allowForeground() {
+	procAllowSetForegroundWindow.Call(asfwAny)
+}
+
+// focusConsoleWindow restores and raises the console window of this process.
+func focusConsoleWindow() error {
+	hwnd, _, _ := procGetConsoleWindow.Call()
+	if hwnd == 0 {
+		return fmt.Errorf("no console window")
+	}
+	if iconic, _, _ := procIsIconic.Call(hwnd); iconic != 0 {
+		procShowWindow.Call(hwnd, swRestore)
+	}
+	if ok, _, _ := procSetForegroundWindow.Call(hwnd); ok == 0 {
+		return fmt.Errorf("SetForegroundWindow failed for the console window")
+	}
+	return nil
+}
+
+// volumeKind classifies the volume root (e.g. `D:\`) with GetDriveType:
+// "network", "removable" or "" for local fixed disks and unknown types.
+func volumeKind(root string) string {
+	p, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return ""
+	}
+	t, _, _ := procGetDriveTypeW.Call(uintptr(unsafe.Pointer(p)))
+	switch t {
+	case driveRemote:
+		return "network"
+	case driveRemovable, driveCDROM:
+		return "removable"
+	}
+	return ""
+}
+
+// launchAsUser starts exe with args as another account via
+// CreateProcessWithLogonW. The password is only passed to Windows.
+func launchAsUser(exe string, args []string, dir, user, domain, password string) error {
+	line := syscall.EscapeArg(exe)
+	for _, a := range args {
+		line += " " + syscall.EscapeArg(a)
+	}
+	ptr := func(s string) *uint16 {
+		if s == "" {
+			return nil
+		}
+		p, _ := syscall.UTF16PtrFromString(s)
+		return p
+	}
+	cmdLine, err := syscall.UTF16FromString(line) // must be writable
+	if err != nil {
+		return err
+	}
+	var si syscall.StartupInfo
+	si.Cb = uint32(unsafe.Sizeof(si))
+	var pi syscall.ProcessInformation
+	ok, _, callErr := procCreateProcessWithLogonW.Call(
+		uintptr(unsafe.Pointer(ptr(user))), uintptr(unsafe.Pointer(ptr(domain))), uintptr(unsafe.Pointer(ptr(password))),
+		logonWithProfile, uintptr(unsafe.Pointer(ptr(exe))), uintptr(unsafe.Pointer(&cmdLine[0])),
+		0, 0, uintptr(unsafe.Pointer(ptr(dir))),
+		uintptr(unsafe.Pointer(&si)), uintptr(unsafe.Pointer(&pi)))
+	if ok == 0 {
+		if errno, isErrno := callErr.(syscall.Errno); isErrno && errno == errorLogonFailure {
+			return errLogonFailed
+		}
+		return fmt.Errorf("CreateProcessWithLogon: %w", callErr)
+	}
+	syscall.CloseHandle(pi.Thread)
+	syscall.CloseHandle(pi.Process)
+	return nil
+}